@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The control API (`ralph run --listen addr`) exposes the same runState the
+// TUI uses over HTTP, so other tools can watch and steer a long run:
+//
+//	GET  /api/status      phase, iteration, spend and budget
+//	GET  /api/stories     stories with their status
+//	GET  /api/output      agent output as a server-sent event stream
+//	POST /api/pause       pause after the current iteration
+//	POST /api/resume      continue a paused run
+//	POST /api/abort       stop the run, killing the agent
+//	POST /api/skip        skip a story (?id=US-002, or the next one)
+
+type apiStatus struct {
+	Tool           string     `json:"tool"`
+	Project        string     `json:"project"`
+	Branch         string     `json:"branch"`
+	Phase          string     `json:"phase"`
+	Paused         bool       `json:"pause_requested"`
+	WaitUntil      *time.Time `json:"usage_limit_resets_at,omitempty"`
+	Iteration      int        `json:"iteration"`
+	MaxIterations  int        `json:"max_iterations"`
+	StartedAt      time.Time  `json:"started_at"`
+	ElapsedSeconds float64    `json:"elapsed_seconds"`
+	CostUSD        float64    `json:"cost_usd"`
+	Tokens         int        `json:"tokens"`
+	MaxCostUSD     float64    `json:"max_cost_usd,omitempty"`
+	MaxTokens      int        `json:"max_tokens,omitempty"`
+	MaxSeconds     float64    `json:"max_duration_seconds,omitempty"`
+	Passing        int        `json:"passing"`
+	Total          int        `json:"total"`
+	Skipped        []string   `json:"skipped"`
+}
+
+type apiStory struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Priority int    `json:"priority"`
+	Status   string `json:"status"` // passing, pending, skipped or next
+}
+
+// status returns a snapshot of the run for the control API.
+func (s *runState) status() apiStatus {
+	s.mu.Lock()
+	st := apiStatus{
+		Tool:           s.tool,
+		Phase:          s.phase,
+		Paused:         s.pause || pauseFileExists(s.ws.dir),
+		Iteration:      s.iteration,
+		MaxIterations:  s.maxIterations,
+		StartedAt:      s.startedAt,
+		ElapsedSeconds: time.Since(s.startedAt).Seconds(),
+		CostUSD:        s.spent.costUSD,
+		Tokens:         s.spent.tokens,
+		MaxCostUSD:     s.budget.maxCost,
+		MaxTokens:      s.budget.maxTokens,
+		MaxSeconds:     s.budget.maxDuration.Seconds(),
+		Skipped:        append([]string{}, s.skipped...),
+	}
+	if !s.waitUntil.IsZero() {
+		t := s.waitUntil
+		st.WaitUntil = &t
+	}
+	s.mu.Unlock()
+
+	if p, _, _ := loadPRDFile(s.ws.prd); p != nil {
+		st.Project, st.Branch = p.Project, p.BranchName
+		st.Passing, st.Total = p.passingCount(), len(p.UserStories)
+	}
+	return st
+}
+
+// stories returns the PRD's stories with the status the TUI shows for them.
+func (s *runState) stories() []apiStory {
+	list := []apiStory{}
+	p, _, _ := loadPRDFile(s.ws.prd)
+	if p == nil {
+		return list
+	}
+	skipped := s.skippedStories()
+	next := nextStory(p, skipped)
+	for _, st := range p.UserStories {
+		status := "pending"
+		switch {
+		case st.Passes:
+			status = "passing"
+		case contains(skipped, st.ID):
+			status = "skipped"
+		case next != nil && next.ID == st.ID:
+			status = "next"
+		}
+		list = append(list, apiStory{ID: st.ID, Title: st.Title, Priority: st.Priority, Status: status})
+	}
+	return list
+}
+
+func newAPIHandler(state *runState) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, state.status())
+	})
+	mux.HandleFunc("GET /api/stories", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, state.stories())
+	})
+	mux.HandleFunc("GET /api/output", func(w http.ResponseWriter, r *http.Request) {
+		streamOutput(w, r, state.output)
+	})
+	mux.HandleFunc("POST /api/pause", sameOrigin(func(w http.ResponseWriter, r *http.Request) {
+		state.setPause(true)
+		logInfo("Pause requested through the control API")
+		writeJSON(w, http.StatusOK, state.status())
+	}))
+	mux.HandleFunc("POST /api/resume", sameOrigin(func(w http.ResponseWriter, r *http.Request) {
+		state.setPause(false)
+		logInfo("Resume requested through the control API")
+		writeJSON(w, http.StatusOK, state.status())
+	}))
+	mux.HandleFunc("POST /api/abort", sameOrigin(func(w http.ResponseWriter, r *http.Request) {
+		logWarning("Aborting (control API)")
+		state.abort()
+		writeJSON(w, http.StatusOK, map[string]any{"aborted": true})
+	}))
+	mux.HandleFunc("POST /api/skip", sameOrigin(func(w http.ResponseWriter, r *http.Request) {
+		id := state.skip(r.URL.Query().Get("id"))
+		if id == "" {
+			writeJSON(w, http.StatusConflict, map[string]any{"error": "no story left to skip"})
+			return
+		}
+		logInfo("Skipping %s", id)
+		writeJSON(w, http.StatusOK, map[string]any{"skipped": id})
+	}))
+	return mux
+}
+
+// sameOrigin rejects state-changing requests that a browser sends from
+// another site, such as a form on a web page posting to /api/abort. Clients
+// like curl send no Origin; the dashboard's origin is the API itself or
+// `ralph serve`, whose proxy passes its own Host through.
+func sameOrigin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+				writeJSON(w, http.StatusForbidden, map[string]any{"error": "cross-origin request from " + origin})
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+// isLoopback reports whether host, without a port, names the loopback
+// interface.
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// loopbackHost rejects requests whose Host is not a loopback name or IP when
+// the server listens on a loopback addr. A DNS-rebinding page reaches such a
+// server under its own domain, so it passes sameOrigin, but not this.
+func loopbackHost(addr string, h http.Handler) http.Handler {
+	if host, _, err := net.SplitHostPort(addr); err != nil || !isLoopback(host) {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if name, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = name
+		}
+		if !isLoopback(host) {
+			writeJSON(w, http.StatusForbidden, map[string]any{"error": "unexpected Host " + r.Host})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// streamOutput sends the last ?tail= lines (default 100) of buf and then each
+// new line as a server-sent event until the client goes away.
+func streamOutput(w http.ResponseWriter, r *http.Request, buf *lineBuffer) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	n := 100
+	if v, err := strconv.Atoi(r.URL.Query().Get("tail")); err == nil && v >= 0 {
+		n = v
+	}
+
+	lines, cancel := buf.subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	if n > 0 {
+		for _, l := range buf.tail(n) {
+			fmt.Fprintf(w, "data: %s\n\n", stripANSI(l))
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case l := <-lines:
+			fmt.Fprintf(w, "data: %s\n\n", stripANSI(l))
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// startAPI serves the control API on addr until the returned server is
+// closed.
+func startAPI(addr string, state *runState) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil && !isLoopback(host) {
+		logWarning("Control API on %s has no authentication; anyone who can reach it can steer this run", addr)
+	}
+	srv := &http.Server{Handler: loopbackHost(addr, newAPIHandler(state))}
+	go srv.Serve(ln)
+	logInfo("Control API listening on http://%s", ln.Addr())
+	return srv, nil
+}
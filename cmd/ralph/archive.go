@@ -0,0 +1,405 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// archiveEntry describes one folder under archive/.
+type archiveEntry struct {
+	name    string
+	path    string
+	date    time.Time
+	prd     *prd         // nil when prd.json is missing or unreadable
+	meta    *archiveMeta // nil for archives made before meta.json existed
+	hasProg bool
+}
+
+// archiveMeta is written to meta.json in each archive folder so that the
+// archive is a complete record of the run.
+type archiveMeta struct {
+	Name             string       `json:"name"`
+	Project          string       `json:"project"`
+	Branch           string       `json:"branch"`
+	RalphVersion     string       `json:"ralphVersion"`
+	Tool             string       `json:"tool"`
+	ArchivedAt       time.Time    `json:"archivedAt"`
+	StartedAt        time.Time    `json:"startedAt"`
+	EndedAt          time.Time    `json:"endedAt"`
+	Runs             int          `json:"runs"`
+	Iterations       int          `json:"iterations"`
+	FailedIterations int          `json:"failedIterations"`
+	CostUSD          float64      `json:"costUSD"`
+	Tokens           int          `json:"tokens"`
+	FinalCommit      string       `json:"finalCommit"`
+	Journal          []journalRun `json:"journal"`
+	Transcripts      []string     `json:"transcripts"`
+}
+
+// archiveJournal copies the run journal and transcripts into folder.
+func archiveJournal(stateDir, folder string) error {
+	src := ralphDir(stateDir)
+	if _, err := os.Stat(filepath.Join(src, journalFile)); err == nil {
+		if err := copyFile(filepath.Join(src, journalFile), filepath.Join(folder, journalFile)); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(filepath.Join(src, transcriptsDir)); err == nil {
+		if err := copyDir(filepath.Join(src, transcriptsDir), filepath.Join(folder, transcriptsDir)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeArchiveMeta summarizes the archived run in folder/meta.json.
+func writeArchiveMeta(stateDir, folder, branch string) error {
+	meta := archiveMeta{
+		Name:         filepath.Base(folder),
+		Branch:       branch,
+		RalphVersion: version,
+		ArchivedAt:   time.Now(),
+		FinalCommit:  gitHead(stateDir),
+		Journal:      []journalRun{},
+		Transcripts:  []string{},
+	}
+	if p, _, _ := loadPRD(folder); p != nil {
+		meta.Project = p.Project
+	}
+
+	if j, err := loadJournal(stateDir); err == nil {
+		meta.Journal = j.Runs
+		meta.Runs = len(j.Runs)
+		for i, run := range j.Runs {
+			if i == 0 {
+				meta.StartedAt = run.StartedAt
+			}
+			meta.EndedAt = run.EndedAt
+			meta.Tool = run.Tool
+			for k, it := range run.Iterations {
+				// Retries share their iteration's number; only the last
+				// attempt decides whether the iteration failed.
+				if it.Retry == 0 {
+					meta.Iterations++
+				}
+				last := k == len(run.Iterations)-1 || run.Iterations[k+1].Retry == 0
+				if last && it.Error != "" {
+					meta.FailedIterations++
+				}
+				meta.CostUSD += it.CostUSD
+				meta.Tokens += it.Tokens
+				if it.Transcript != "" {
+					meta.Transcripts = append(meta.Transcripts, it.Transcript)
+				}
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(folder, "meta.json"), data, 0644)
+}
+
+func loadArchiveMeta(folder string) *archiveMeta {
+	data, err := os.ReadFile(filepath.Join(folder, "meta.json"))
+	if err != nil {
+		return nil
+	}
+	var meta archiveMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil
+	}
+	return &meta
+}
+
+// listArchives returns the archived runs in stateDir, newest first.
+func listArchives(stateDir string) ([]archiveEntry, error) {
+	root := filepath.Join(stateDir, "archive")
+	dirs, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+
+	var entries []archiveEntry
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		e := archiveEntry{name: d.Name(), path: filepath.Join(root, d.Name())}
+		e.meta = loadArchiveMeta(e.path)
+		if e.meta != nil {
+			e.date = e.meta.ArchivedAt
+		} else if len(e.name) >= 10 {
+			e.date, _ = time.Parse("2006-01-02", e.name[:10])
+		}
+		if e.date.IsZero() {
+			if info, err := d.Info(); err == nil {
+				e.date = info.ModTime()
+			}
+		}
+		e.prd, _, _ = loadPRD(e.path)
+		_, err := os.Stat(filepath.Join(e.path, "progress.txt"))
+		e.hasProg = err == nil
+		entries = append(entries, e)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].date.Equal(entries[j].date) {
+			return entries[i].date.After(entries[j].date)
+		}
+		return entries[i].name > entries[j].name
+	})
+	return entries, nil
+}
+
+func findArchive(stateDir, name string) (*archiveEntry, error) {
+	entries, err := listArchives(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].name == name {
+			return &entries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no archive named %q (see 'ralph archive list')", name)
+}
+
+// runArchiveCommand handles `ralph archive [--reset]` and
+// `ralph archive <list|show|restore|prune>`, and returns the process exit
+// code.
+func runArchiveCommand(cfg *config) int {
+	sub := ""
+	if len(cfg.args) > 0 {
+		sub = cfg.args[0]
+	}
+
+	var err error
+	switch sub {
+	case "":
+		err = archiveNow(cfg.ws, cfg.reset)
+	case "list":
+		err = archiveList(cfg.ws.dir)
+	case "show", "restore":
+		if len(cfg.args) < 2 {
+			err = fmt.Errorf("usage: ralph archive %s <name>", sub)
+		} else if sub == "show" {
+			err = archiveShow(cfg.ws.dir, cfg.args[1])
+		} else {
+			err = archiveRestore(cfg.ws, cfg.args[1])
+		}
+	case "prune":
+		if cfg.keep < 0 {
+			err = fmt.Errorf("usage: ralph archive prune --keep N")
+		} else {
+			err = archivePrune(cfg.ws.dir, cfg.keep)
+		}
+	default:
+		err = fmt.Errorf("unknown archive command %q: use list, show, restore or prune", sub)
+	}
+
+	if err != nil {
+		logError("%v", err)
+		return exitError
+	}
+	return 0
+}
+
+func archiveList(stateDir string) error {
+	entries, err := listArchives(stateDir)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		logInfo("No archived runs in %s", filepath.Join(stateDir, "archive"))
+		return nil
+	}
+
+	fmt.Fprintf(uiOut, "  %s%-36s %-10s %-8s %s%s\n", colorBold, "NAME", "DATE", "PASSING", "PROJECT", colorReset)
+	for _, e := range entries {
+		passing, project := "-", "-"
+		if e.prd != nil {
+			passing = fmt.Sprintf("%d/%d", e.prd.passingCount(), len(e.prd.UserStories))
+			project = e.prd.Project
+		}
+		fmt.Fprintf(uiOut, "  %-36s %s%-10s%s %-8s %s\n", e.name, colorMuted, e.date.Format("2006-01-02"), colorReset, passing, project)
+	}
+	return nil
+}
+
+func archiveShow(stateDir, name string) error {
+	e, err := findArchive(stateDir, name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(uiOut, "  %s[ARCHIVE]%s %s\n", colorMuted, colorReset, e.path)
+	fmt.Fprintf(uiOut, "  %s[DATE   ]%s %s\n", colorMuted, colorReset, e.date.Format("2006-01-02"))
+	if m := e.meta; m != nil {
+		fmt.Fprintf(uiOut, "  %s[RALPH  ]%s %s, %s\n", colorMuted, colorReset, m.RalphVersion, m.Tool)
+		fmt.Fprintf(uiOut, "  %s[RUNS   ]%s %d runs, %d iterations (%d failed)\n", colorMuted, colorReset, m.Runs, m.Iterations, m.FailedIterations)
+		if !m.StartedAt.IsZero() {
+			fmt.Fprintf(uiOut, "  %s[TIME   ]%s %s - %s\n", colorMuted, colorReset, m.StartedAt.Format(time.RFC1123), m.EndedAt.Format(time.RFC1123))
+		}
+		if m.CostUSD > 0 || m.Tokens > 0 {
+			fmt.Fprintf(uiOut, "  %s[SPENT  ]%s $%.2f, %d tokens\n", colorMuted, colorReset, m.CostUSD, m.Tokens)
+		}
+		if m.FinalCommit != "" {
+			fmt.Fprintf(uiOut, "  %s[COMMIT ]%s %s\n", colorMuted, colorReset, m.FinalCommit)
+		}
+		if len(m.Transcripts) > 0 {
+			fmt.Fprintf(uiOut, "  %s[LOGS   ]%s %d transcripts in %s\n", colorMuted, colorReset, len(m.Transcripts), filepath.Join(e.path, transcriptsDir))
+		}
+	}
+	if e.prd == nil {
+		logWarning("No readable prd.json in this archive")
+	} else {
+		fmt.Fprintf(uiOut, "  %s[PROJECT]%s %s\n", colorMuted, colorReset, e.prd.Project)
+		fmt.Fprintf(uiOut, "  %s[BRANCH ]%s %s%s%s\n", colorMuted, colorReset, colorOrcGold, e.prd.BranchName, colorReset)
+		fmt.Fprintf(uiOut, "  %s[PASSING]%s %d/%d\n\n", colorMuted, colorReset, e.prd.passingCount(), len(e.prd.UserStories))
+		for _, s := range e.prd.UserStories {
+			status := fmt.Sprintf("%s○%s", colorOrcIron, colorReset)
+			if s.Passes {
+				status = fmt.Sprintf("%s✔%s", colorSuccess, colorReset)
+			}
+			fmt.Fprintf(uiOut, "    [%s] %-8s %s\n", status, s.ID, s.Title)
+		}
+	}
+
+	if e.hasProg {
+		fmt.Fprintf(uiOut, "\n  %sPROGRESS:%s\n", colorBold, colorReset)
+		for _, h := range recentProgress(e.path, 10) {
+			fmt.Fprintf(uiOut, "    %s\n", h)
+		}
+	}
+	return nil
+}
+
+// archiveRestore brings an archived run back into the workspace. The current
+// run, if any, is archived first so nothing is lost.
+func archiveRestore(ws workspace, name string) error {
+	e, err := findArchive(ws.dir, name)
+	if err != nil {
+		return err
+	}
+	if e.prd == nil {
+		return fmt.Errorf("archive %q has no readable prd.json", name)
+	}
+
+	if _, err := archiveCurrent(ws); err != nil {
+		return fmt.Errorf("archiving current run: %w", err)
+	}
+	if err := resetJournal(ws.dir); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(ws.dir, progressArchiveFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := copyFile(filepath.Join(e.path, "prd.json"), ws.prd); err != nil {
+		return err
+	}
+	if e.hasProg {
+		if err := copyFile(filepath.Join(e.path, "progress.txt"), filepath.Join(ws.dir, "progress.txt")); err != nil {
+			return err
+		}
+	} else if err := resetProgressFile(ws.dir); err != nil {
+		return err
+	}
+	if older := filepath.Join(e.path, progressArchiveFile); fileExists(older) {
+		if err := copyFile(older, filepath.Join(ws.dir, progressArchiveFile)); err != nil {
+			return err
+		}
+	}
+	if err := restoreJournal(e.path, ws.dir); err != nil {
+		return err
+	}
+	if e.prd.BranchName != "" {
+		if err := writeLastBranch(ws.dir, e.prd.BranchName); err != nil {
+			return err
+		}
+	}
+
+	logSuccess("Restored %s (branch %s)", name, e.prd.BranchName)
+	return nil
+}
+
+// archiveCurrent archives the run in the workspace, if there is a PRD, and
+// returns the archive folder ("" when there was nothing to archive).
+func archiveCurrent(ws workspace) (string, error) {
+	current, exists, _ := loadPRDFile(ws.prd)
+	if !exists {
+		return "", nil
+	}
+	branch := readLastBranch(ws.dir)
+	if current != nil && current.BranchName != "" {
+		branch = current.BranchName
+	}
+	if branch == "" {
+		branch = "unnamed"
+	}
+	return archiveRun(ws, branch)
+}
+
+// archiveNow archives the current run on demand. With reset it then clears
+// the workspace like `ralph clean`, ready for the next PRD.
+func archiveNow(ws workspace, reset bool) error {
+	folder, err := archiveCurrent(ws)
+	if err != nil {
+		return err
+	}
+	if folder == "" {
+		return fmt.Errorf("no %s: nothing to archive", ws.prd)
+	}
+	if reset {
+		return cleanWorkspace(ws)
+	}
+	return nil
+}
+
+// restoreJournal copies an archive's journal and transcripts back into
+// .ralph/ so later runs keep adding to the same record.
+func restoreJournal(folder, stateDir string) error {
+	dst := ralphDir(stateDir)
+	if _, err := os.Stat(filepath.Join(folder, journalFile)); err == nil {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+		if err := copyFile(filepath.Join(folder, journalFile), filepath.Join(dst, journalFile)); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(filepath.Join(folder, transcriptsDir)); err == nil {
+		return copyDir(filepath.Join(folder, transcriptsDir), filepath.Join(dst, transcriptsDir))
+	}
+	return nil
+}
+
+// archivePrune deletes all but the newest keep archives.
+func archivePrune(stateDir string, keep int) error {
+	entries, err := listArchives(stateDir)
+	if err != nil {
+		return err
+	}
+	if len(entries) <= keep {
+		logInfo("Nothing to prune (%d archives, keeping %d)", len(entries), keep)
+		return nil
+	}
+	for _, e := range entries[keep:] {
+		if err := os.RemoveAll(e.path); err != nil {
+			return fmt.Errorf("removing %s: %w", e.name, err)
+		}
+		logInfo("Removed %s", e.name)
+	}
+	logSuccess("Pruned %d archives, kept %d", len(entries)-keep, keep)
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// usage is what a tool reported spending during one or more iterations.
+// Only claude reports usage; amp iterations count as zero.
+type usage struct {
+	costUSD float64
+	tokens  int
+}
+
+func (u *usage) add(o usage) {
+	u.costUSD += o.costUSD
+	u.tokens += o.tokens
+}
+
+// budget holds the run limits. Zero values mean unlimited.
+type budget struct {
+	maxCost     float64
+	maxTokens   int
+	maxDuration time.Duration
+}
+
+// exceeded returns which budget tripped ("cost", "tokens" or "duration"),
+// or an empty string when the run is still within all limits.
+func (b budget) exceeded(spent usage, elapsed time.Duration) string {
+	switch {
+	case b.maxCost > 0 && spent.costUSD >= b.maxCost:
+		return "cost"
+	case b.maxTokens > 0 && spent.tokens >= b.maxTokens:
+		return "tokens"
+	case b.maxDuration > 0 && elapsed >= b.maxDuration:
+		return "duration"
+	}
+	return ""
+}
+
+// limit describes the configured limit for a tripped budget.
+func (b budget) limit(name string, spent usage, elapsed time.Duration) string {
+	switch name {
+	case "cost":
+		return fmt.Sprintf("$%.2f spent of $%.2f", spent.costUSD, b.maxCost)
+	case "tokens":
+		return fmt.Sprintf("%d tokens used of %d", spent.tokens, b.maxTokens)
+	case "duration":
+		return fmt.Sprintf("%s elapsed of %s", elapsed.Round(time.Second), b.maxDuration)
+	}
+	return ""
+}
+
+// claudeResult is the subset of claude's result event we use.
+type claudeResult struct {
+	Result       string  `json:"result"`
+	TotalCostUSD float64 `json:"total_cost_usd"`
+	Usage        struct {
+		InputTokens              int `json:"input_tokens"`
+		OutputTokens             int `json:"output_tokens"`
+		CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+		CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	} `json:"usage"`
+}
+
+// parseClaudeResult extracts the response text and usage from claude's
+// result event. ok is false when the line is not a JSON result (e.g. the
+// CLI failed before producing one), in which case callers use the raw
+// output.
+func parseClaudeResult(raw string) (text string, u usage, ok bool) {
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, "{") {
+		return "", usage{}, false
+	}
+	var r claudeResult
+	if err := json.Unmarshal([]byte(raw), &r); err != nil {
+		return "", usage{}, false
+	}
+	u.costUSD = r.TotalCostUSD
+	u.tokens = r.Usage.InputTokens + r.Usage.OutputTokens +
+		r.Usage.CacheCreationInputTokens + r.Usage.CacheReadInputTokens
+	return r.Result, u, true
+}
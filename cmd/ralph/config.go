@@ -4,13 +4,47 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type config struct {
-	command       string
-	tool          string
-	maxIterations int
-	workDir       string // current working directory where prd.json/progress.txt live
+	command          string
+	tool             string
+	maxIterations    int
+	maxCost          float64       // USD, 0 = unlimited
+	maxTokens        int           // 0 = unlimited
+	maxDuration      time.Duration // 0 = unlimited
+	stallAfter       int           // iterations without a newly passing story, 0 = never
+	splitAfter       int           // split a story after it fails this many iterations in a row, 0 = never
+	retries          int           // retries of a transiently failing iteration
+	iterationTimeout time.Duration // 0 = no limit per iteration
+	compactAfter     int           // compact progress.txt once it has more entries than this, 0 = never
+	gates            []string      // quality gate commands run once the agent reports completion
+	review           *reviewConfig // review each newly passing story with a second agent, nil = off
+	model            string        // claude --model; set for the reviewer from review.Model
+	output           string        // text, plain or json
+	quiet            bool          // suppress info logs and agent output
+	tui              bool          // full-screen dashboard instead of line output
+	step             bool          // confirm before each iteration
+	listen           string        // address for the HTTP control API (serve: for the dashboard), "" = off
+	api              string        // serve: address of the run's control API
+	args             []string      // positional arguments for subcommands (archive, progress, plan)
+	keep             int           // archive prune --keep, -1 = not given
+	reset            bool          // archive --reset: clean the workspace after archiving
+	sync             bool          // init-instructions --sync: CLAUDE.md imports AGENTS.md
+	yes              bool          // init, plan: accept every default without asking
+	archiveOnDone    bool          // archive and reset the workspace when the run completes
+	hooks            projectHooks  // lifecycle hook commands from the project config
+	notify           []notifySink  // notification sinks from the project config
+	stateDir         string        // where prd.json/progress.txt live; defaults to workDir
+	prd              string        // --prd: a named PRD (prds/<name>.json) or a path to one
+	ws               workspace     // the PRD this command works on, resolved from stateDir and prd
+	workDir          string        // current working directory, the repository the agent works in
+}
+
+func (c *config) budget() budget {
+	return budget{maxCost: c.maxCost, maxTokens: c.maxTokens, maxDuration: c.maxDuration}
 }
 
 func parseArgs(args []string) (*config, error) {
@@ -18,6 +52,9 @@ func parseArgs(args []string) (*config, error) {
 		command:       "run",
 		tool:          "claude",
 		maxIterations: 10,
+		retries:       3,
+		output:        outputText,
+		keep:          -1,
 	}
 
 	i := 0
@@ -38,6 +75,39 @@ func parseArgs(args []string) (*config, error) {
 		case "setup":
 			cfg.command = "setup"
 			i = 1
+		case "pause":
+			cfg.command = "pause"
+			i = 1
+		case "resume":
+			cfg.command = "resume"
+			i = 1
+		case "archive":
+			cfg.command = "archive"
+			i = 1
+		case "migrate-state":
+			cfg.command = "migrate-state"
+			i = 1
+		case "list":
+			cfg.command = "list"
+			i = 1
+		case "progress":
+			cfg.command = "progress"
+			i = 1
+		case "serve":
+			cfg.command = "serve"
+			i = 1
+		case "doctor":
+			cfg.command = "doctor"
+			i = 1
+		case "init":
+			cfg.command = "init"
+			i = 1
+		case "plan":
+			cfg.command = "plan"
+			i = 1
+		case "init-instructions":
+			cfg.command = "init-instructions"
+			i = 1
 		}
 	}
 
@@ -50,16 +120,186 @@ func parseArgs(args []string) (*config, error) {
 		case arg == "--help" || arg == "-h":
 			printUsage()
 			os.Exit(0)
-		case arg == "--tool":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("--tool requires a value")
-			}
-			i++
-			cfg.tool = args[i]
-		case len(arg) > 7 && arg[:7] == "--tool=":
-			cfg.tool = arg[7:]
+		case isFlag(arg, "--tool"):
+			v, err := flagValue(args, &i, "--tool")
+			if err != nil {
+				return nil, err
+			}
+			cfg.tool = v
+		case isFlag(arg, "--output"):
+			v, err := flagValue(args, &i, "--output")
+			if err != nil {
+				return nil, err
+			}
+			if v != outputText && v != outputPlain && v != outputJSON {
+				return nil, fmt.Errorf("invalid --output '%s': must be 'text', 'plain' or 'json'", v)
+			}
+			cfg.output = v
+		case arg == "--json":
+			cfg.output = outputJSON
+		case arg == "--quiet" || arg == "-q":
+			cfg.quiet = true
+		case arg == "--tui":
+			cfg.tui = true
+		case arg == "--step":
+			cfg.step = true
+		case arg == "--reset":
+			cfg.reset = true
+		case arg == "--sync":
+			cfg.sync = true
+		case arg == "--yes" || arg == "-y":
+			cfg.yes = true
+		case arg == "--archive-on-complete":
+			cfg.archiveOnDone = true
+		case isFlag(arg, "--max-cost"):
+			v, err := flagValue(args, &i, "--max-cost")
+			if err != nil {
+				return nil, err
+			}
+			f, err := strconv.ParseFloat(strings.TrimPrefix(v, "$"), 64)
+			if err != nil || f < 0 {
+				return nil, fmt.Errorf("invalid --max-cost '%s': must be a dollar amount", v)
+			}
+			cfg.maxCost = f
+		case isFlag(arg, "--max-tokens"):
+			v, err := flagValue(args, &i, "--max-tokens")
+			if err != nil {
+				return nil, err
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid --max-tokens '%s': must be a whole number", v)
+			}
+			cfg.maxTokens = n
+		case isFlag(arg, "--max-duration"):
+			v, err := flagValue(args, &i, "--max-duration")
+			if err != nil {
+				return nil, err
+			}
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("invalid --max-duration '%s': use a duration like 30m or 2h", v)
+			}
+			cfg.maxDuration = d
+		case isFlag(arg, "--stall-after"):
+			v, err := flagValue(args, &i, "--stall-after")
+			if err != nil {
+				return nil, err
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid --stall-after '%s': must be a whole number", v)
+			}
+			cfg.stallAfter = n
+		case isFlag(arg, "--split-after"):
+			v, err := flagValue(args, &i, "--split-after")
+			if err != nil {
+				return nil, err
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid --split-after '%s': must be a whole number", v)
+			}
+			cfg.splitAfter = n
+		case isFlag(arg, "--retries"):
+			v, err := flagValue(args, &i, "--retries")
+			if err != nil {
+				return nil, err
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid --retries '%s': must be a whole number", v)
+			}
+			cfg.retries = n
+		case isFlag(arg, "--iteration-timeout"):
+			v, err := flagValue(args, &i, "--iteration-timeout")
+			if err != nil {
+				return nil, err
+			}
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("invalid --iteration-timeout '%s': use a duration like 20m", v)
+			}
+			cfg.iterationTimeout = d
+		case isFlag(arg, "--compact-after"):
+			v, err := flagValue(args, &i, "--compact-after")
+			if err != nil {
+				return nil, err
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid --compact-after '%s': must be a whole number", v)
+			}
+			cfg.compactAfter = n
+		case isFlag(arg, "--state-dir"):
+			v, err := flagValue(args, &i, "--state-dir")
+			if err != nil {
+				return nil, err
+			}
+			cfg.stateDir = v
+		case isFlag(arg, "--prd"):
+			v, err := flagValue(args, &i, "--prd")
+			if err != nil {
+				return nil, err
+			}
+			cfg.prd = v
+		case isFlag(arg, "--listen"):
+			v, err := flagValue(args, &i, "--listen")
+			if err != nil {
+				return nil, err
+			}
+			cfg.listen = v
+		case isFlag(arg, "--api"):
+			v, err := flagValue(args, &i, "--api")
+			if err != nil {
+				return nil, err
+			}
+			cfg.api = v
+		case isFlag(arg, "--keep"):
+			v, err := flagValue(args, &i, "--keep")
+			if err != nil {
+				return nil, err
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid --keep '%s': must be a whole number", v)
+			}
+			cfg.keep = n
+		case arg == "--review":
+			if cfg.review == nil {
+				cfg.review = &reviewConfig{}
+			}
+		case isFlag(arg, "--review-tool"):
+			v, err := flagValue(args, &i, "--review-tool")
+			if err != nil {
+				return nil, err
+			}
+			if v != "amp" && v != "claude" {
+				return nil, fmt.Errorf("invalid --review-tool '%s': must be 'amp' or 'claude'", v)
+			}
+			if cfg.review == nil {
+				cfg.review = &reviewConfig{}
+			}
+			cfg.review.Tool = v
+		case isFlag(arg, "--review-model"):
+			v, err := flagValue(args, &i, "--review-model")
+			if err != nil {
+				return nil, err
+			}
+			if cfg.review == nil {
+				cfg.review = &reviewConfig{}
+			}
+			cfg.review.Model = v
+		case isFlag(arg, "--gate"):
+			v, err := flagValue(args, &i, "--gate")
+			if err != nil {
+				return nil, err
+			}
+			cfg.gates = append(cfg.gates, v)
 		default:
-			if cfg.command == "prompt" && cfg.tool == "claude" {
+			if cfg.command == "archive" || cfg.command == "progress" || cfg.command == "plan" {
+				cfg.args = append(cfg.args, arg)
+			} else if cfg.command == "prompt" && cfg.tool == "claude" {
 				// For prompt command, first positional argument is tool name
 				cfg.tool = arg
 			} else if cfg.command == "skill" && cfg.tool == "claude" {
@@ -72,13 +312,41 @@ func parseArgs(args []string) (*config, error) {
 		i++
 	}
 
+	if cfg.tui && cfg.output == outputJSON {
+		return nil, fmt.Errorf("--tui cannot be combined with --output json")
+	}
+	if cfg.step && (cfg.tui || cfg.output == outputJSON) {
+		return nil, fmt.Errorf("--step cannot be combined with --tui or --output json")
+	}
+
 	if cfg.command != "prompt" && cfg.command != "skill" && cfg.tool != "amp" && cfg.tool != "claude" {
 		return nil, fmt.Errorf("invalid tool '%s': must be 'amp' or 'claude'", cfg.tool)
 	}
+	if cfg.tool == "amp" && (cfg.maxCost > 0 || cfg.maxTokens > 0) {
+		return nil, fmt.Errorf("--max-cost and --max-tokens need usage reports, which amp doesn't give; use --max-duration")
+	}
 
 	return cfg, nil
 }
 
+// isFlag reports whether arg is name, either bare or in --name=value form.
+func isFlag(arg, name string) bool {
+	return arg == name || strings.HasPrefix(arg, name+"=")
+}
+
+// flagValue returns the value for the flag at args[*i], consuming the next
+// argument when the value is not given inline with '='.
+func flagValue(args []string, i *int, name string) (string, error) {
+	if v, ok := strings.CutPrefix(args[*i], name+"="); ok {
+		return v, nil
+	}
+	if *i+1 >= len(args) {
+		return "", fmt.Errorf("%s requires a value", name)
+	}
+	*i++
+	return args[*i], nil
+}
+
 func printUsage() {
 	fmt.Println(`ralph - autonomous AI agent loop
 
@@ -88,13 +356,67 @@ Commands:
   run       Start the AI agent loop (default)
   prompt    Print the prompt for a tool (claude or amp)
   skill     Print a skill instruction (prd or ralph)
+  init      Set up ralph in this repository: detect the project, choose quality
+            gates and the state directory, create prd.json, CLAUDE.md/AGENTS.md
+            and optionally install the skills (--yes accepts the defaults)
+  plan      Have the agent write a PRD for a feature description with the prd
+            skill, convert it to prd.json with the ralph skill, and show the
+            stories before offering to start the run
   setup     Print first-time setup commands for Claude skills
+  pause     Pause the loop running in this directory after its current iteration
+  resume    Resume a paused loop
+  archive   Archive the current run now (--reset to clean up afterwards), or
+            manage archives: list, show <name>, restore <name>, prune --keep N
+  list      List the PRDs in this repository (prd.json and prds/*.json)
+  progress  Show progress.txt: show (entries and learnings), patterns, tail [n],
+            or compact [--keep N] to move older entries to progress-archive.txt
+  serve     Web dashboard for a run started with --listen, plus its journal,
+            archives and transcripts (--listen for the dashboard address,
+            default 127.0.0.1:7780; --api for the run's, default 127.0.0.1:7777)
+  doctor    Check the agent, git repository, CLAUDE.md/AGENTS.md, PRD, installed
+            skills and --gate commands before a run
+  init-instructions
+            Create CLAUDE.md (claude) or AGENTS.md (amp) from the detected
+            language and build commands; --sync writes AGENTS.md and makes
+            CLAUDE.md import it so both tools share one file
   clean     Remove prd.json, progress.txt, and .ralph-branch
+  migrate-state
+            Move state files from the repo root into --state-dir and save the setting
 
 Options:
-  --tool     AI tool to use: amp or claude (default: claude)
-  --version  Show version
-  --help     Show this help
+  --tool          AI tool to use: amp or claude (default: claude)
+  --state-dir     Directory for prd.json, progress.txt and archive/ (default: .)
+  --prd           Work on a named PRD: a name (prds/<name>.json) or a path to one
+  --output        Output format: text, plain or json (default: text)
+  --json          Shorthand for --output json
+  --quiet, -q     Only print warnings, errors and the final outcome
+  --tui           Full-screen dashboard (keys: p pause/resume, s skip story, q abort)
+  --step          Confirm before each iteration, showing the last iteration's diff
+  --listen        Serve the HTTP control API on this address, e.g. 127.0.0.1:7777
+  --archive-on-complete
+                  Archive the run and reset the workspace once all stories pass
+  --max-cost      Stop once reported spend exceeds this many USD (claude only)
+  --max-tokens    Stop once reported token usage exceeds this (claude only)
+  --max-duration  Stop once wall time exceeds this, e.g. 30m or 2h
+  --stall-after   Stop after N iterations with no newly passing story
+  --split-after   Have the agent split a story into smaller ones after it fails
+                  N iterations in a row
+  --retries       Retries of an iteration that failed transiently (rate limit,
+                  overload, timeout) with exponential backoff (default: 3)
+  --iteration-timeout
+                  Kill and retry an iteration that runs longer than this, e.g. 20m
+  --compact-after Compact progress.txt before an iteration once it has more
+                  than N entries, keeping the newest 5 (or --keep N)
+  --review        Have a reviewer agent check each story the agent marks passing
+                  against its diff and acceptance criteria; a rejection flips
+                  passes back and adds the feedback to the story's notes
+  --review-tool   Tool for the reviewer: amp or claude (default: --tool)
+  --review-model  Model for a claude reviewer, e.g. opus
+                  (--review-tool and --review-model imply --review)
+  --gate          Quality gate command run on completion (repeatable; default:
+                  the gates saved in .ralph/config.json by ralph init)
+  --version       Show version
+  --help          Show this help
 
 Arguments:
   max_iterations  Maximum iterations to run (default: 10)
@@ -108,16 +430,59 @@ Examples:
   ralph skill ralph        # Print the Ralph converter skill
   ralph 20                 # Run with claude, 20 iterations
   ralph --tool amp         # Run with amp, 10 iterations
+  ralph --max-cost 5 --max-duration 2h
+                           # Stop at $5 spent or after 2 hours
+  ralph archive --reset    # Archive the current run and start fresh
+  ralph archive list       # List archived runs with pass counts
+  ralph archive prune --keep 5
+                           # Delete all but the 5 newest archives
+  ralph --gate "go test ./..."
+                           # Verify completion with a quality gate
+  ralph run --prd auth     # Work on prds/auth.json
+  ralph list               # Show every PRD with its progress
+  ralph --listen 127.0.0.1:7777
+                           # Watch and steer the run over HTTP
+  ralph serve              # Dashboard on http://127.0.0.1:7780
+  ralph progress patterns  # Print the Codebase Patterns the agents recorded
+  ralph init               # Detect the project and create config and prd.json
+  ralph plan "add dark mode to the settings page"
+                           # Generate prd.json with the agent
+  ralph doctor --gate "go test ./..."
+                           # Check everything a run needs
 
-File Locations:
-  prd.json      Current working directory
-  progress.txt  Current working directory (created automatically)
-  archive/      Current working directory (for archiving old runs)
+File Locations (in the state directory, the current directory by default):
+  prd.json      The PRD being worked on
+  progress.txt  Progress log (created automatically)
+  progress-archive.txt
+                Older progress entries moved out by compaction
+  archive/      Archived previous runs
+  .ralph/       Run journal, transcripts and patterns.md (patterns kept across PRDs)
+  prds/         Named PRDs; prds/<name>.json keeps its progress.txt,
+                archive/ and journal in prds/<name>/
+
+  .ralph/config.json at the repository root holds project settings
+  (e.g. {"stateDir": "ralph"}).
 
 Skills:
   prd     Generate PRDs from feature descriptions
   ralph   Convert PRDs to prd.json format
 
+Output:
+  text mode falls back to plain (no colors, no spinner) when stdout is not a
+  terminal or NO_COLOR is set. json mode writes one JSON event per line to
+  stdout; agent output always goes to stderr.
+
+Exit Codes:
+  0    All stories complete
+  1    Usage or setup error
+  2    Max iterations reached
+  3    Budget exceeded (cost, tokens or duration)
+  4    Stalled (see --stall-after)
+  5    Invalid prd.json
+  6    Agent binary (claude/amp) not found
+  7    Quality gate failed
+  130  Interrupted (Ctrl-C / SIGTERM)
+
 The prompts and skills are embedded in the binary.`)
 }
 
@@ -0,0 +1,184 @@
+package main
+
+// dashboardHTML is the single-page dashboard served by `ralph serve`. It has
+// no external assets: styles and script are inline, and all data comes from
+// the /api/ (live run) and /data/ (journal, archives) endpoints in serve.go.
+const dashboardHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>ralph</title>
+<style>
+  :root { --bg: #151210; --panel: #1f1a17; --line: #3a302a; --text: #ddd3c7; --muted: #8a7d70;
+          --gold: #ffaf00; --blood: #af0000; --rust: #af5f00; --ok: #ffaf00; }
+  * { box-sizing: border-box; }
+  body { margin: 0; background: var(--bg); color: var(--text); font: 14px/1.45 ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; }
+  header { display: flex; gap: 24px; align-items: baseline; padding: 12px 20px; border-bottom: 1px solid var(--line); flex-wrap: wrap; }
+  header h1 { margin: 0; font-size: 18px; color: var(--blood); letter-spacing: 2px; }
+  header .phase { color: var(--gold); }
+  header .muted, .muted { color: var(--muted); }
+  button { background: var(--panel); color: var(--text); border: 1px solid var(--line); padding: 4px 10px; font: inherit; cursor: pointer; }
+  button:hover { border-color: var(--gold); }
+  main { display: grid; grid-template-columns: 340px 1fr; gap: 16px; padding: 16px 20px; }
+  section { background: var(--panel); border: 1px solid var(--line); padding: 10px 12px; min-width: 0; }
+  section h2 { margin: 0 0 8px; font-size: 12px; letter-spacing: 1px; color: var(--muted); text-transform: uppercase; }
+  .col { display: flex; flex-direction: column; gap: 16px; min-width: 0; }
+  ul { list-style: none; margin: 0; padding: 0; }
+  li { padding: 2px 0; white-space: nowrap; overflow: hidden; text-overflow: ellipsis; }
+  .passing { color: var(--ok); } .next { color: var(--text); font-weight: bold; } .pending { color: var(--muted); } .skipped { color: var(--muted); text-decoration: line-through; }
+  pre { margin: 0; white-space: pre-wrap; word-break: break-word; max-height: 420px; overflow: auto; }
+  #output { height: 420px; }
+  .bar { display: flex; align-items: center; gap: 8px; padding: 2px 0; cursor: pointer; }
+  .bar span.fill { display: inline-block; height: 10px; background: var(--rust); }
+  .bar.failed span.fill { background: var(--blood); }
+  .bar.complete span.fill { background: var(--gold); }
+  .link { color: var(--gold); cursor: pointer; }
+  .error { color: var(--blood); }
+</style>
+</head>
+<body>
+<header>
+  <h1>RALPH</h1>
+  <span id="project">-</span>
+  <span id="phase" class="phase">no run</span>
+  <span id="meters" class="muted"></span>
+  <span style="flex:1"></span>
+  <button onclick="control('pause')">pause</button>
+  <button onclick="control('resume')">resume</button>
+  <button onclick="control('skip')">skip story</button>
+  <button onclick="if (confirm('Abort the run?')) control('abort')">abort</button>
+</header>
+<main>
+  <div class="col">
+    <section><h2>Stories <span id="passing" class="muted"></span></h2><ul id="stories"></ul></section>
+    <section><h2>Iterations</h2><div id="timeline" class="muted">no journal yet</div></section>
+    <section><h2>Archives</h2><ul id="archives" class="muted"></ul></section>
+  </div>
+  <div class="col">
+    <section><h2>Output</h2><pre id="output"></pre></section>
+    <section><h2 id="viewer-title">Viewer</h2><pre id="viewer" class="muted">Select an iteration or an archive.</pre></section>
+  </div>
+</main>
+<script>
+function $(id) { return document.getElementById(id); }
+function esc(s) { return String(s).replace(/[&<>"]/g, function (c) { return {'&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;'}[c]; }); }
+function get(path) { return fetch(path).then(function (r) { return r.ok ? r.json() : Promise.reject(r); }); }
+function secs(a, b) { return Math.max(0, (new Date(b) - new Date(a)) / 1000); }
+function dur(s) { s = Math.round(s); return s < 60 ? s + 's' : Math.floor(s / 60) + 'm' + (s % 60) + 's'; }
+
+function control(action) {
+  fetch('/api/' + action, {method: 'POST'}).then(refresh);
+}
+
+function refresh() {
+  get('/api/status').then(function (s) {
+    $('project').textContent = s.project + '  ' + s.branch;
+    $('phase').textContent = s.phase + (s.usage_limit_resets_at ? ' (usage limit resets in ' + dur(secs(new Date(), s.usage_limit_resets_at)) + ')' : '') + (s.pause_requested && s.phase !== 'paused' ? ' (pause requested)' : '') + '  ' + s.iteration + '/' + s.max_iterations;
+    var m = dur(s.elapsed_seconds);
+    if (s.cost_usd || s.max_cost_usd) m += '  $' + s.cost_usd.toFixed(2) + (s.max_cost_usd ? ' / $' + s.max_cost_usd.toFixed(2) : '');
+    if (s.tokens || s.max_tokens) m += '  ' + s.tokens + (s.max_tokens ? ' / ' + s.max_tokens : '') + ' tokens';
+    $('meters').textContent = m;
+  }, function () {
+    $('phase').textContent = 'no run listening';
+    $('meters').textContent = '';
+  });
+  get('/api/stories').then(function (list) {
+    var passing = list.filter(function (s) { return s.status === 'passing'; }).length;
+    $('passing').textContent = passing + '/' + list.length;
+    $('stories').innerHTML = list.map(function (s) {
+      var mark = {passing: '✔', next: '▶', skipped: '-', pending: '○'}[s.status];
+      return '<li class="' + s.status + '">' + mark + ' ' + esc(s.id) + ' ' + esc(s.title) + '</li>';
+    }).join('');
+  }, function () {});
+  get('/data/journal').then(renderTimeline, function () {});
+}
+
+function renderTimeline(j) {
+  var its = [];
+  (j.runs || []).forEach(function (run) {
+    (run.iterations || []).forEach(function (it) { its.push(it); });
+  });
+  if (!its.length) { $('timeline').textContent = 'no iterations yet'; return; }
+  var longest = Math.max.apply(null, its.map(function (it) { return secs(it.startedAt, it.endedAt); })) || 1;
+  $('timeline').innerHTML = its.map(function (it, i) {
+    var d = secs(it.startedAt, it.endedAt);
+    var cls = it.error ? 'failed' : it.complete ? 'complete' : '';
+    var file = (it.transcript || '').replace(/^transcripts\//, '');
+    return '<div class="bar ' + cls + '" data-file="' + esc(file) + '" title="' + esc(it.error || '') + '">' +
+      '<span class="muted">#' + it.number + '</span>' +
+      '<span class="fill" style="width:' + Math.max(2, 160 * d / longest) + 'px"></span>' +
+      '<span>' + dur(d) + (it.costUSD ? '  $' + it.costUSD.toFixed(2) : '') + '</span></div>';
+  }).join('');
+  Array.prototype.forEach.call($('timeline').querySelectorAll('.bar'), function (el) {
+    el.onclick = function () { if (el.dataset.file) view('Transcript ' + el.dataset.file, '/data/transcripts/' + el.dataset.file); };
+  });
+}
+
+function view(title, path) {
+  $('viewer-title').textContent = title;
+  fetch(path).then(function (r) { return r.text(); }).then(function (t) { $('viewer').textContent = t; $('viewer').className = ''; });
+}
+
+function loadArchives() {
+  get('/data/archives').then(function (list) {
+    if (!list.length) { $('archives').innerHTML = '<li>no archives</li>'; return; }
+    $('archives').innerHTML = list.map(function (a) {
+      return '<li><span class="link" data-name="' + esc(a.name) + '">' + esc(a.name) + '</span> <span class="muted">' + a.passing + '/' + a.total + '</span></li>';
+    }).join('');
+    Array.prototype.forEach.call($('archives').querySelectorAll('.link'), function (el) {
+      el.onclick = function () { showArchive(el.dataset.name); };
+    });
+  }, function () {});
+}
+
+function showArchive(name) {
+  get('/data/archives/' + encodeURIComponent(name)).then(function (a) {
+    $('viewer-title').textContent = 'Archive ' + name;
+    var html = '';
+    if (a.meta) {
+      html += esc(a.meta.ralphVersion + ' · ' + a.meta.tool + ' · ' + a.meta.runs + ' runs · ' + a.meta.iterations + ' iterations · $' + a.meta.costUSD.toFixed(2)) + '\n\n';
+    }
+    if (a.prd) {
+      html += esc(a.prd.project + '  ' + a.prd.branchName) + '\n';
+      (a.prd.userStories || []).forEach(function (s) {
+        html += (s.passes ? '✔ ' : '○ ') + esc(s.id + ' ' + s.title) + '\n';
+      });
+    }
+    if (a.meta && a.meta.transcripts.length) {
+      html += '\nTranscripts:\n';
+      a.meta.transcripts.forEach(function (t) {
+        var file = t.replace(/^transcripts\//, '');
+        html += '  <span class="link" data-file="' + esc(file) + '">' + esc(file) + '</span>\n';
+      });
+    }
+    $('viewer').innerHTML = html;
+    $('viewer').className = '';
+    Array.prototype.forEach.call($('viewer').querySelectorAll('.link'), function (el) {
+      el.onclick = function () { view('Archive ' + name + ' · ' + el.dataset.file, '/data/archives/' + encodeURIComponent(name) + '/transcripts/' + el.dataset.file); };
+    });
+  });
+}
+
+function stream() {
+  var out = $('output');
+  var es = new EventSource('/api/output');
+  es.onopen = function () { out.textContent = ''; }; // the stream replays recent lines
+  es.onmessage = function (e) {
+    var atBottom = out.scrollTop + out.clientHeight >= out.scrollHeight - 4;
+    out.textContent += e.data + '\n';
+    if (out.textContent.length > 200000) out.textContent = out.textContent.slice(-150000);
+    if (atBottom) out.scrollTop = out.scrollHeight;
+  };
+  es.onerror = function () { es.close(); setTimeout(stream, 3000); };
+}
+
+refresh();
+loadArchives();
+stream();
+setInterval(refresh, 2000);
+setInterval(loadArchives, 30000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// detectedProject is a language or build system found in the repository,
+// with the commands that build, check and test it.
+type detectedProject struct {
+	language string // e.g. "Go", "TypeScript"
+	marker   string // the file it was detected from, e.g. go.mod
+	commands []projectCommand
+}
+
+type projectCommand struct {
+	kind string // build, typecheck, lint or test
+	line string
+}
+
+// checks returns the commands suitable as quality gates: everything but
+// the build, which the checks and tests cover.
+func (p detectedProject) checks() []string {
+	var lines []string
+	for _, c := range p.commands {
+		if c.kind != "build" {
+			lines = append(lines, c.line)
+		}
+	}
+	return lines
+}
+
+// detectProjects looks for the build files of the languages ralph knows at
+// the repository root. A Makefile is only used when nothing else is found.
+func detectProjects(workDir string) []detectedProject {
+	var found []detectedProject
+	if fileExists(filepath.Join(workDir, "go.mod")) {
+		found = append(found, detectedProject{"Go", "go.mod", []projectCommand{
+			{"build", "go build ./..."},
+			{"lint", "go vet ./..."},
+			{"test", "go test ./..."},
+		}})
+	}
+	if fileExists(filepath.Join(workDir, "Cargo.toml")) {
+		found = append(found, detectedProject{"Rust", "Cargo.toml", []projectCommand{
+			{"build", "cargo build"},
+			{"lint", "cargo clippy -- -D warnings"},
+			{"test", "cargo test"},
+		}})
+	}
+	if p, ok := detectNode(workDir); ok {
+		found = append(found, p)
+	}
+	if p, ok := detectPython(workDir); ok {
+		found = append(found, p)
+	}
+	if len(found) == 0 {
+		if p, ok := detectMake(workDir); ok {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+// detectNode reads package.json scripts, running them with the package
+// manager whose lockfile is present.
+func detectNode(workDir string) (detectedProject, bool) {
+	data, err := os.ReadFile(filepath.Join(workDir, "package.json"))
+	if err != nil {
+		return detectedProject{}, false
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	json.Unmarshal(data, &pkg)
+
+	pm := "npm"
+	for _, lock := range [][2]string{{"pnpm-lock.yaml", "pnpm"}, {"yarn.lock", "yarn"}, {"bun.lockb", "bun"}, {"bun.lock", "bun"}} {
+		if fileExists(filepath.Join(workDir, lock[0])) {
+			pm = lock[1]
+			break
+		}
+	}
+	p := detectedProject{language: "JavaScript", marker: "package.json"}
+	if fileExists(filepath.Join(workDir, "tsconfig.json")) {
+		p.language = "TypeScript"
+	}
+	for _, kind := range []string{"build", "typecheck", "lint", "test"} {
+		if _, ok := pkg.Scripts[kind]; ok {
+			p.commands = append(p.commands, projectCommand{kind, pm + " run " + kind})
+		}
+	}
+	return p, true
+}
+
+// detectPython uses pytest, ruff and mypy when pyproject.toml mentions them,
+// through uv when the project is managed by it.
+func detectPython(workDir string) (detectedProject, bool) {
+	data, err := os.ReadFile(filepath.Join(workDir, "pyproject.toml"))
+	if err != nil {
+		return detectedProject{}, false
+	}
+	run := ""
+	if fileExists(filepath.Join(workDir, "uv.lock")) {
+		run = "uv run "
+	}
+	p := detectedProject{language: "Python", marker: "pyproject.toml"}
+	text := string(data)
+	if strings.Contains(text, "mypy") {
+		p.commands = append(p.commands, projectCommand{"typecheck", run + "mypy ."})
+	}
+	if strings.Contains(text, "ruff") {
+		p.commands = append(p.commands, projectCommand{"lint", run + "ruff check ."})
+	}
+	if strings.Contains(text, "pytest") || fileExists(filepath.Join(workDir, "tests")) {
+		p.commands = append(p.commands, projectCommand{"test", run + "pytest"})
+	}
+	return p, true
+}
+
+var makeTargetPattern = regexp.MustCompile(`(?m)^(build|lint|check|test):`)
+
+func detectMake(workDir string) (detectedProject, bool) {
+	data, err := os.ReadFile(filepath.Join(workDir, "Makefile"))
+	if err != nil {
+		return detectedProject{}, false
+	}
+	p := detectedProject{language: "Make", marker: "Makefile"}
+	for _, m := range makeTargetPattern.FindAllStringSubmatch(string(data), -1) {
+		kind := m[1]
+		if kind == "check" {
+			kind = "lint"
+		}
+		p.commands = append(p.commands, projectCommand{kind, "make " + m[1]})
+	}
+	return p, true
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Outcomes of a doctor check.
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+type check struct {
+	name   string
+	status string
+	detail string
+}
+
+// runDoctor checks that a run could start and do useful work: the agent, the
+// repository, project instructions, the PRD, installed skills and quality
+// gates. It returns exitError if any check fails; warnings don't.
+func runDoctor(cfg *config) int {
+	checks := doctorChecks(cfg)
+	failed, warned := 0, 0
+	for _, c := range checks {
+		switch c.status {
+		case checkFail:
+			failed++
+		case checkWarn:
+			warned++
+		}
+		if outMode == outputJSON {
+			emit("check", map[string]any{"name": c.name, "status": c.status, "detail": c.detail})
+			continue
+		}
+		mark, color := "✔", colorSuccess
+		switch c.status {
+		case checkWarn:
+			mark, color = "!", colorWarning
+		case checkFail:
+			mark, color = "✘", colorError
+		}
+		fmt.Fprintf(uiOut, "  %s%s%s %-14s %s\n", color, mark, colorReset, c.name, c.detail)
+	}
+
+	if outMode == outputJSON {
+		emit("doctor", map[string]any{"checks": len(checks), "warnings": warned, "failures": failed})
+	} else {
+		blankLine()
+	}
+	if failed > 0 {
+		logError("%d of %d checks failed, %d warnings", failed, len(checks), warned)
+		return exitError
+	}
+	if warned > 0 {
+		logWarning("All checks passed with %d warnings", warned)
+	} else {
+		logSuccess("All %d checks passed", len(checks))
+	}
+	return 0
+}
+
+func doctorChecks(cfg *config) []check {
+	checks := []check{checkAgent(cfg.tool)}
+	if cfg.review != nil && cfg.review.Tool != "" && cfg.review.Tool != cfg.tool {
+		reviewer := checkAgent(cfg.review.Tool)
+		reviewer.name = "reviewer"
+		checks = append(checks, reviewer)
+	}
+	checks = append(checks, checkGit(cfg.workDir, cfg.ws)...)
+	checks = append(checks, checkInstructions(cfg.workDir, cfg.tool), checkPRD(cfg.workDir, cfg.ws))
+	if cfg.tool == "claude" {
+		checks = append(checks, checkSkills()...)
+	}
+	return append(checks, checkGates(cfg.gates)...)
+}
+
+func checkAgent(tool string) check {
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		return check{"agent", checkFail, fmt.Sprintf("%s not found on PATH", tool)}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		return check{"agent", checkWarn, fmt.Sprintf("%s found at %s, but --version failed: %v", tool, path, err)}
+	}
+	version, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return check{"agent", checkPass, fmt.Sprintf("%s %s (%s)", tool, version, path)}
+}
+
+// checkGit checks that the agent has a repository to commit to, and whether
+// its branch and working tree are ready for the PRD.
+func checkGit(workDir string, ws workspace) []check {
+	if _, err := exec.LookPath("git"); err != nil {
+		return []check{{"git", checkFail, "git not found on PATH"}}
+	}
+	if _, err := git(workDir, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return []check{{"git", checkFail, "not a git repository; the agent commits each story"}}
+	}
+	checks := []check{{"git", checkPass, "repository at " + workDir}}
+
+	branch, err := git(workDir, "rev-parse", "--abbrev-ref", "HEAD")
+	switch {
+	case err != nil:
+		checks = append(checks, check{"branch", checkWarn, "no commits yet"})
+	default:
+		p, _, _ := loadPRDFile(ws.prd)
+		if p != nil && p.BranchName != "" && p.BranchName != branch {
+			checks = append(checks, check{"branch", checkWarn, fmt.Sprintf("on %s; the agent will switch to %s", branch, p.BranchName)})
+		} else {
+			checks = append(checks, check{"branch", checkPass, "on " + branch})
+		}
+	}
+
+	if status, err := git(workDir, "status", "--porcelain"); err == nil && status != "" {
+		n := len(strings.Split(status, "\n"))
+		checks = append(checks, check{"working tree", checkWarn, fmt.Sprintf("%d uncommitted changes; the agent may commit them with its own", n)})
+	} else if err == nil {
+		checks = append(checks, check{"working tree", checkPass, "clean"})
+	}
+	return checks
+}
+
+func checkInstructions(workDir, tool string) check {
+	if path := findInstructions(workDir, tool); path != "" {
+		rel, _ := filepath.Rel(workDir, path)
+		return check{"instructions", checkPass, rel}
+	}
+	return check{"instructions", checkWarn, fmt.Sprintf("no %s; %s may lack project instructions (see ralph init-instructions)", instructionFiles(tool)[0], tool)}
+}
+
+// checkPRD validates the PRD's stories and checks that something is left
+// to do.
+func checkPRD(workDir string, ws workspace) check {
+	name, _ := filepath.Rel(workDir, ws.prd)
+	p, exists, err := loadPRDFile(ws.prd)
+	switch {
+	case err != nil:
+		return check{"prd", checkFail, err.Error()}
+	case !exists:
+		return check{"prd", checkWarn, fmt.Sprintf("no %s yet (ralph skill ralph converts a markdown PRD)", name)}
+	case len(p.UserStories) == 0:
+		return check{"prd", checkWarn, name + " has no user stories"}
+	}
+
+	if problems := validatePRD(p); len(problems) > 0 {
+		return check{"prd", checkFail, name + ": " + strings.Join(problems, "; ")}
+	}
+
+	detail := fmt.Sprintf("%s: %s, %d/%d stories passing", name, p.Project, p.passingCount(), len(p.UserStories))
+	switch {
+	case p.BranchName == "":
+		return check{"prd", checkWarn, detail + "; no branchName"}
+	case p.passingCount() == len(p.UserStories):
+		return check{"prd", checkWarn, detail + "; nothing left to do"}
+	}
+	return check{"prd", checkPass, detail}
+}
+
+// checkSkills compares the Claude skills installed by `ralph setup` with the
+// ones embedded in this binary.
+func checkSkills() []check {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return []check{{"skills", checkWarn, err.Error()}}
+	}
+	var checks []check
+	for _, skill := range []string{"prd", "ralph"} {
+		name := "skill " + skill
+		data, err := os.ReadFile(skillPath(home, skill))
+		switch {
+		case err != nil:
+			checks = append(checks, check{name, checkWarn, "not installed (see ralph setup)"})
+		case string(data) != getSkill(skill)+"\n":
+			checks = append(checks, check{name, checkWarn, "outdated; reinstall it with ralph setup"})
+		default:
+			checks = append(checks, check{name, checkPass, "up to date"})
+		}
+	}
+	return checks
+}
+
+// checkGates checks that each --gate command's program can be found. The
+// gates are not run, and a shell builtin is only warned about.
+func checkGates(gates []string) []check {
+	var checks []check
+	for _, g := range gates {
+		fields := strings.Fields(g)
+		if len(fields) == 0 {
+			checks = append(checks, check{"gate", checkFail, "empty gate command"})
+			continue
+		}
+		if _, err := exec.LookPath(fields[0]); err != nil {
+			checks = append(checks, check{"gate", checkWarn, fmt.Sprintf("%q: %s not found on PATH", g, fields[0])})
+			continue
+		}
+		checks = append(checks, check{"gate", checkPass, g})
+	}
+	return checks
+}
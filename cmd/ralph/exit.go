@@ -0,0 +1,28 @@
+package main
+
+// Exit codes for `ralph run`. They are part of the CLI contract so that CI
+// wrappers can tell outcomes apart; do not renumber.
+const (
+	exitComplete       = 0   // completion marker seen (and gates passed)
+	exitError          = 1   // usage or setup error
+	exitMaxIterations  = 2   // max iterations reached without completion
+	exitBudgetExceeded = 3   // --max-cost, --max-tokens or --max-duration tripped
+	exitStalled        = 4   // no story passed for --stall-after iterations
+	exitInvalidPRD     = 5   // prd.json could not be read or parsed
+	exitAgentNotFound  = 6   // claude/amp binary not on PATH
+	exitGateFailed     = 7   // a --gate command failed after completion
+	exitInterrupted    = 130 // SIGINT/SIGTERM
+)
+
+// exitCodeNames maps exit codes to the outcome names used in JSON output.
+var exitCodeNames = map[int]string{
+	exitComplete:       "complete",
+	exitError:          "error",
+	exitMaxIterations:  "max_iterations",
+	exitBudgetExceeded: "budget_exceeded",
+	exitStalled:        "stalled",
+	exitInvalidPRD:     "invalid_prd",
+	exitAgentNotFound:  "agent_not_found",
+	exitGateFailed:     "gate_failed",
+	exitInterrupted:    "interrupted",
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// shellCommand builds a command that runs line through the platform shell.
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", line)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", line)
+}
+
+// runGates runs each quality gate command in order and returns an error for
+// the first one that fails. Gate output goes with the agent's, so the TUI
+// and --quiet apply to it too.
+func runGates(ctx context.Context, workDir string, gates []string) error {
+	for _, gate := range gates {
+		logInfo("Running gate: %s", gate)
+		cmd := shellCommand(ctx, gate)
+		cmd.Dir = workDir
+		cmd.Stdout = agentOutput
+		cmd.Stderr = agentOutput
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("gate %q failed: %w", gate, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// git runs a git command in workDir and returns its trimmed output.
+func git(workDir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = workDir
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// gitHead returns the current commit SHA, or "" outside a repository or
+// before the first commit.
+func gitHead(workDir string) string {
+	sha, err := git(workDir, "rev-parse", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return sha
+}
+
+// gitDiff returns the changes since the given commit, including commits made
+// since then and uncommitted work. With stat it returns the summary only.
+func gitDiff(workDir, since string, stat bool) (string, error) {
+	args := []string{"diff"}
+	if stat {
+		args = append(args, "--stat")
+	}
+	if since != "" {
+		args = append(args, since)
+	}
+	return git(workDir, args...)
+}
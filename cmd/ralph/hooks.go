@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// projectHooks are shell commands from .ralph/config.json run at points in
+// the loop's lifecycle. Each runs in the repository root with RALPH_*
+// variables describing the run added to the environment.
+type projectHooks struct {
+	PreRun          []string `json:"preRun,omitempty"`          // once, before the first iteration
+	PreIteration    []string `json:"preIteration,omitempty"`    // before each agent run
+	PostIteration   []string `json:"postIteration,omitempty"`   // after each agent run
+	OnStoryComplete []string `json:"onStoryComplete,omitempty"` // for each story that starts passing
+	OnComplete      []string `json:"onComplete,omitempty"`      // when all stories are complete
+	OnFailure       []string `json:"onFailure,omitempty"`       // when the run ends any other way
+}
+
+// runHooks runs the commands for a hook in order and returns an error for
+// the first one that fails. Output goes with the agent's, as for gates.
+func runHooks(ctx context.Context, workDir, hook string, cmds []string, env ...string) error {
+	for _, line := range cmds {
+		logInfo("Running %s hook: %s", hook, line)
+		cmd := shellCommand(ctx, line)
+		cmd.Dir = workDir
+		cmd.Env = append(append(os.Environ(), "RALPH_HOOK="+hook), env...)
+		cmd.Stdout = agentOutput
+		cmd.Stderr = agentOutput
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s hook %q failed: %w", hook, line, err)
+		}
+	}
+	return nil
+}
+
+// agentExitCode is the agent's exit status for RALPH_EXIT_CODE: 0 when it
+// succeeded, -1 when it could not be run or was killed by a signal.
+func agentExitCode(err error) int {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// newlyPassing returns the stories that pass in after but did not in before.
+func newlyPassing(before, after *prd) []userStory {
+	passed := map[string]bool{}
+	if before != nil {
+		for _, s := range before.UserStories {
+			if s.Passes {
+				passed[s.ID] = true
+			}
+		}
+	}
+	var stories []userStory
+	if after != nil {
+		for _, s := range after.UserStories {
+			if s.Passes && !passed[s.ID] {
+				stories = append(stories, s)
+			}
+		}
+	}
+	return stories
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// prompter asks the questions of `ralph init`. With yes set, or once input
+// runs out, every question takes its default.
+type prompter struct {
+	in  *bufio.Reader
+	yes bool
+}
+
+func (p *prompter) ask(question, def string) string {
+	fmt.Fprintf(uiOut, "  %s%s%s [%s]: ", colorOrcGold, question, colorReset, def)
+	if p.yes {
+		fmt.Fprintln(uiOut)
+		return def
+	}
+	line, err := p.in.ReadString('\n')
+	if err == io.EOF && line == "" {
+		fmt.Fprintln(uiOut)
+		p.yes = true
+	}
+	if line = strings.TrimSpace(line); line != "" {
+		return line
+	}
+	return def
+}
+
+func (p *prompter) confirm(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	switch strings.ToLower(p.ask(question, hint)) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return def
+}
+
+// runInit bootstraps ralph in the repository: it detects the project type,
+// proposes quality gates, saves them in .ralph/config.json, creates an empty
+// PRD and offers to create the instructions file and install the skills.
+func runInit(cfg *config, in io.Reader) error {
+	p := &prompter{in: bufio.NewReader(in), yes: cfg.yes}
+	workDir := cfg.workDir
+
+	projects := detectProjects(workDir)
+	var gates []string
+	if len(projects) == 0 {
+		logInfo("No go.mod, Cargo.toml, package.json, pyproject.toml or Makefile found")
+	}
+	for _, proj := range projects {
+		logInfo("Detected %s (%s)", proj.language, proj.marker)
+		gates = append(gates, proj.checks()...)
+	}
+
+	pc, err := loadProjectConfig(workDir)
+	if err != nil {
+		return err
+	}
+	if len(cfg.gates) > 0 {
+		gates = cfg.gates
+	} else if len(pc.Gates) > 0 {
+		gates = pc.Gates
+	}
+	def := strings.Join(gates, "; ")
+	if def == "" {
+		def = "none"
+	}
+	answer := p.ask("Quality gates, separated by ';'", def)
+	gates = nil
+	if answer != "none" {
+		for _, g := range strings.Split(answer, ";") {
+			if g = strings.TrimSpace(g); g != "" {
+				gates = append(gates, g)
+			}
+		}
+	}
+
+	stateDir := pc.StateDir
+	if cfg.stateDir != workDir {
+		stateDir, _ = filepath.Rel(workDir, cfg.stateDir)
+	}
+	if stateDir == "" {
+		stateDir = "."
+	}
+	stateDir = filepath.ToSlash(filepath.Clean(p.ask("State directory for prd.json and progress.txt", stateDir)))
+
+	pc.Gates = gates
+	pc.StateDir = ""
+	if stateDir != "." {
+		pc.StateDir = stateDir
+	}
+	if err := saveProjectConfig(workDir, pc); err != nil {
+		return err
+	}
+	logSuccess("Saved %s", projectConfigFile)
+
+	// Like applyProjectConfig, an absolute state directory is used as is.
+	cfg.stateDir = filepath.FromSlash(stateDir)
+	if !filepath.IsAbs(cfg.stateDir) {
+		cfg.stateDir = filepath.Join(workDir, cfg.stateDir)
+	}
+	cfg.ws = resolveWorkspace(cfg)
+	if fileExists(cfg.ws.prd) {
+		logInfo("%s already exists; leaving it alone", filepath.Base(cfg.ws.prd))
+	} else {
+		branch := p.ask("Branch for the first feature", "ralph/feature")
+		if err := writePRDSkeleton(cfg.ws.prd, filepath.Base(workDir), branch); err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(workDir, cfg.ws.prd)
+		logSuccess("Created %s", rel)
+	}
+
+	if findInstructions(workDir, cfg.tool) == "" && p.confirm("Create "+instructionFiles(cfg.tool)[0]+" from the detected project?", true) {
+		if err := initInstructions(cfg); err != nil {
+			return err
+		}
+	}
+
+	if cfg.tool == "claude" && p.confirm("Install the prd and ralph skills into ~/.claude/skills?", false) {
+		if err := installSkills(); err != nil {
+			return err
+		}
+	}
+
+	blankLine()
+	logSuccess("Ready. Next: describe a feature with the prd skill, convert it into stories, then run 'ralph doctor' and 'ralph'")
+	return nil
+}
+
+// writePRDSkeleton writes a PRD with no stories yet.
+func writePRDSkeleton(path, project, branch string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return savePRDFile(path, &prd{Project: project, BranchName: branch, UserStories: []userStory{}})
+}
+
+// skillPath is where `ralph setup` tells the user to install a skill.
+func skillPath(home, skill string) string {
+	return filepath.Join(home, ".claude", "skills", "ralph-"+skill, "SKILL.md")
+}
+
+// installSkills writes the embedded skills to their skillPath.
+func installSkills() error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	for _, skill := range []string{"prd", "ralph"} {
+		path := skillPath(home, skill)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(getSkill(skill)+"\n"), 0644); err != nil {
+			return err
+		}
+		logSuccess("Installed %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// instructionFiles returns the project instruction files a tool reads, in
+// the order it looks for them. Claude reads CLAUDE.md; Amp reads AGENTS.md
+// and falls back to AGENT.md and CLAUDE.md.
+func instructionFiles(tool string) []string {
+	if tool == "amp" {
+		return []string{"AGENTS.md", "AGENT.md", "CLAUDE.md"}
+	}
+	return []string{"CLAUDE.md", filepath.Join(".claude", "CLAUDE.md")}
+}
+
+// findInstructions returns the path of the instructions file tool would read
+// in workDir, or "" if there is none.
+func findInstructions(workDir, tool string) string {
+	for _, name := range instructionFiles(tool) {
+		if path := filepath.Join(workDir, name); fileExists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// agentsImport is the line that makes Claude read AGENTS.md from CLAUDE.md.
+const agentsImport = "@AGENTS.md"
+
+// initInstructions scaffolds the instructions file for cfg.tool from the
+// detected languages and build commands. Existing files are left alone. With
+// sync, AGENTS.md holds the instructions and CLAUDE.md imports it, so both
+// tools read the same file.
+func initInstructions(cfg *config) error {
+	workDir := cfg.workDir
+	if !cfg.sync {
+		name := instructionFiles(cfg.tool)[0]
+		if existing := findInstructions(workDir, cfg.tool); existing != "" {
+			rel, _ := filepath.Rel(workDir, existing)
+			logInfo("%s already exists; leaving it alone (use --sync to share AGENTS.md with CLAUDE.md)", rel)
+			return nil
+		}
+		return writeInstructions(filepath.Join(workDir, name), scaffoldInstructions(workDir))
+	}
+
+	agents := filepath.Join(workDir, "AGENTS.md")
+	if fileExists(agents) {
+		logInfo("AGENTS.md already exists; leaving it alone")
+	} else if err := writeInstructions(agents, scaffoldInstructions(workDir)); err != nil {
+		return err
+	}
+
+	claude := filepath.Join(workDir, "CLAUDE.md")
+	data, err := os.ReadFile(claude)
+	switch {
+	case os.IsNotExist(err):
+		return writeInstructions(claude, agentsImport+"\n")
+	case err != nil:
+		return err
+	case hasLine(string(data), agentsImport):
+		logInfo("CLAUDE.md already imports AGENTS.md")
+		return nil
+	}
+	text := strings.TrimRight(string(data), "\n") + "\n\n" + agentsImport + "\n"
+	if err := os.WriteFile(claude, []byte(text), 0644); err != nil {
+		return err
+	}
+	logSuccess("Added %s to CLAUDE.md", agentsImport)
+	return nil
+}
+
+func writeInstructions(path, text string) error {
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		return err
+	}
+	logSuccess("Created %s", filepath.Base(path))
+	return nil
+}
+
+func hasLine(text, line string) bool {
+	for _, l := range strings.Split(text, "\n") {
+		if strings.TrimSpace(l) == line {
+			return true
+		}
+	}
+	return false
+}
+
+// scaffoldInstructions writes a starting instructions file: what the project
+// is built with and the commands to run before committing, plus a section
+// for the patterns agents record as they work.
+func scaffoldInstructions(workDir string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\nInstructions for AI coding agents working in this repository.\n", filepath.Base(workDir))
+
+	projects := detectProjects(workDir)
+	if len(projects) > 0 {
+		b.WriteString("\n## Project\n\n")
+		for _, p := range projects {
+			fmt.Fprintf(&b, "- %s (%s)\n", p.language, p.marker)
+		}
+	}
+
+	var commands []projectCommand
+	for _, p := range projects {
+		commands = append(commands, p.commands...)
+	}
+	if len(commands) > 0 {
+		b.WriteString("\n## Commands\n\n")
+		for _, c := range commands {
+			fmt.Fprintf(&b, "- %s: `%s`\n", strings.ToUpper(c.kind[:1])+c.kind[1:], c.line)
+		}
+		b.WriteString("\nRun the checks and tests before every commit; all of them must pass.\n")
+	} else {
+		b.WriteString("\n## Commands\n\n<!-- How to build, lint and test this project. -->\n")
+	}
+
+	b.WriteString("\n## Conventions\n\n<!-- Codebase patterns, gotchas and conventions. Agents add reusable learnings here as they work. -->\n")
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The run journal records every `ralph run` against the current PRD: when it
+// ran, with which tool, and what each iteration did. Agent output for each
+// iteration is kept as a transcript file next to it. Both live in .ralph/
+// and are moved into the archive together with prd.json and progress.txt.
+const (
+	ralphDirName   = ".ralph"
+	journalFile    = "journal.json"
+	transcriptsDir = "transcripts"
+)
+
+type runJournal struct {
+	Runs []journalRun `json:"runs"`
+}
+
+type journalRun struct {
+	StartedAt  time.Time          `json:"startedAt"`
+	EndedAt    time.Time          `json:"endedAt"`
+	Tool       string             `json:"tool"`
+	Version    string             `json:"version"`
+	Branch     string             `json:"branch"`
+	Outcome    string             `json:"outcome"`
+	ExitCode   int                `json:"exitCode"`
+	Iterations []journalIteration `json:"iterations"`
+}
+
+type journalIteration struct {
+	Number     int       `json:"number"`
+	Retry      int       `json:"retry,omitempty"` // 0 for the first attempt
+	StartedAt  time.Time `json:"startedAt"`
+	EndedAt    time.Time `json:"endedAt"`
+	Error      string    `json:"error,omitempty"`
+	CostUSD    float64   `json:"costUSD"`
+	Tokens     int       `json:"tokens"`
+	Complete   bool      `json:"complete"`
+	Transcript string    `json:"transcript,omitempty"` // relative to .ralph/
+}
+
+func ralphDir(stateDir string) string {
+	return filepath.Join(stateDir, ralphDirName)
+}
+
+// loadJournal reads the journal for the current PRD; a missing file yields
+// an empty journal.
+func loadJournal(stateDir string) (*runJournal, error) {
+	data, err := os.ReadFile(filepath.Join(ralphDir(stateDir), journalFile))
+	if os.IsNotExist(err) {
+		return &runJournal{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading journal: %w", err)
+	}
+	var j runJournal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("parsing journal: %w", err)
+	}
+	return &j, nil
+}
+
+func (j *runJournal) save(stateDir string) error {
+	if err := os.MkdirAll(ralphDir(stateDir), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(ralphDir(stateDir), journalFile), data, 0644)
+}
+
+// current returns the run being recorded.
+func (j *runJournal) current() *journalRun {
+	return &j.Runs[len(j.Runs)-1]
+}
+
+// startJournalRun appends a new run to the journal and saves it.
+func startJournalRun(stateDir, tool, branch string) (*runJournal, error) {
+	j, err := loadJournal(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	j.Runs = append(j.Runs, journalRun{
+		StartedAt: time.Now(),
+		Tool:      tool,
+		Version:   version,
+		Branch:    branch,
+		Outcome:   "running",
+	})
+	return j, j.save(stateDir)
+}
+
+// recordIteration writes the iteration's transcript and adds it to the
+// current run.
+func (j *runJournal) recordIteration(stateDir string, it journalIteration, output string) error {
+	run := j.current()
+	name := fmt.Sprintf("%s-iter%02d", run.StartedAt.Format("20060102-150405"), it.Number)
+	if it.Retry > 0 {
+		name += fmt.Sprintf("-r%d", it.Retry)
+	}
+	name += ".txt"
+	dir := filepath.Join(ralphDir(stateDir), transcriptsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(output), 0644); err != nil {
+		return err
+	}
+	it.Transcript = filepath.ToSlash(filepath.Join(transcriptsDir, name))
+	run.Iterations = append(run.Iterations, it)
+	return j.save(stateDir)
+}
+
+// finish records the run's outcome.
+func (j *runJournal) finish(stateDir string, code int) error {
+	run := j.current()
+	run.EndedAt = time.Now()
+	run.Outcome = exitCodeNames[code]
+	run.ExitCode = code
+	return j.save(stateDir)
+}
+
+// resetJournal removes the journal and transcripts, once they have been
+// archived.
+func resetJournal(stateDir string) error {
+	if err := os.RemoveAll(filepath.Join(ralphDir(stateDir), transcriptsDir)); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(ralphDir(stateDir), journalFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -1,28 +1,50 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
+// iterationPause is how long the loop waits between iterations.
+var iterationPause = 2 * time.Second
+
 func main() {
 	cfg, err := parseArgs(os.Args[1:])
 	if err != nil {
 		logError("%v", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	workDir, err := getWorkDir()
 	if err != nil {
 		logError("Getting working directory: %v", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	cfg.workDir = workDir
+	setupOutput(cfg.output, cfg.quiet)
+
+	pc, err := loadProjectConfig(workDir)
+	if err != nil {
+		logError("%v", err)
+		os.Exit(exitError)
+	}
+	applyProjectConfig(cfg, pc)
+	ws := cfg.ws
 
 	// Handle 'prompt' command
 	if cfg.command == "prompt" {
-		fmt.Println(getPrompt(cfg.tool))
+		fmt.Println(promptFor(cfg))
 		os.Exit(0)
 	}
 
@@ -34,9 +56,9 @@ func main() {
 
 	// Handle 'clean' command
 	if cfg.command == "clean" {
-		if err := cleanWorkDir(workDir); err != nil {
+		if err := cleanWorkspace(ws); err != nil {
 			logError("%v", err)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
 		os.Exit(0)
 	}
@@ -47,90 +69,574 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Run command - check for CLAUDE.md
-	if !checkClaudeMD(workDir) {
-		logWarning("No CLAUDE.md found - Claude may lack project instructions")
+	// Handle 'archive' command
+	if cfg.command == "archive" {
+		os.Exit(runArchiveCommand(cfg))
+	}
+
+	// Handle 'list' command
+	if cfg.command == "list" {
+		if err := listPRDs(cfg.stateDir); err != nil {
+			logError("%v", err)
+			os.Exit(exitError)
+		}
+		os.Exit(0)
+	}
+
+	// Handle 'progress' command
+	if cfg.command == "progress" {
+		os.Exit(runProgressCommand(cfg))
+	}
+
+	// Handle 'serve' command
+	if cfg.command == "serve" {
+		os.Exit(runServe(cfg))
+	}
+
+	// Handle 'doctor' command
+	if cfg.command == "doctor" {
+		os.Exit(runDoctor(cfg))
+	}
+
+	// Handle 'init' command
+	if cfg.command == "init" {
+		if err := runInit(cfg, os.Stdin); err != nil {
+			logError("%v", err)
+			os.Exit(exitError)
+		}
+		os.Exit(0)
+	}
+
+	// Handle 'plan' command
+	if cfg.command == "plan" {
+		os.Exit(runPlan(cfg, os.Stdin))
+	}
+
+	// Handle 'init-instructions' command
+	if cfg.command == "init-instructions" {
+		if err := initInstructions(cfg); err != nil {
+			logError("%v", err)
+			os.Exit(exitError)
+		}
+		os.Exit(0)
+	}
+
+	// Handle 'migrate-state' command
+	if cfg.command == "migrate-state" {
+		if err := migrateState(cfg); err != nil {
+			logError("%v", err)
+			os.Exit(exitError)
+		}
+		os.Exit(0)
+	}
+
+	// Handle 'pause' / 'resume' commands for a loop running in this directory
+	if cfg.command == "pause" {
+		if err := writePauseFile(ws.dir); err != nil {
+			logError("%v", err)
+			os.Exit(exitError)
+		}
+		logSuccess("Ralph will pause after the current iteration (run 'ralph resume' to continue)")
+		os.Exit(0)
+	}
+	if cfg.command == "resume" {
+		if err := removePauseFile(ws.dir); err != nil {
+			logError("%v", err)
+			os.Exit(exitError)
+		}
+		logSuccess("Resume requested")
+		os.Exit(0)
+	}
+
+	os.Exit(runLoop(cfg))
+}
+
+// runLoop runs the agent until completion or until a limit stops it, and
+// returns the exit code for the outcome (see exit.go).
+func runLoop(cfg *config) int {
+	if cfg.stateDir == "" {
+		cfg.stateDir = cfg.workDir
+	}
+	if cfg.ws.dir == "" {
+		cfg.ws = resolveWorkspace(cfg)
+	}
+	workDir, ws := cfg.workDir, cfg.ws
+	prdName := filepath.Base(ws.prd)
+	if ws.name != "" {
+		prdName, _ = filepath.Rel(workDir, ws.prd)
+	}
+
+	// Run command - check for the tool's instructions file
+	if findInstructions(workDir, cfg.tool) == "" {
+		logWarning("No %s found - %s may lack project instructions (run 'ralph init-instructions')", instructionFiles(cfg.tool)[0], cfg.tool)
+	}
+
+	if _, err := exec.LookPath(cfg.tool); err != nil {
+		logError("%s not found on PATH: %v", cfg.tool, err)
+		return exitAgentNotFound
 	}
 
 	// Run command - load PRD
 	logInfo("Working directory: %s", workDir)
 
-	p, exists, err := loadPRD(workDir)
+	p, exists, err := loadPRDFile(ws.prd)
 	if err != nil {
 		logError("%v", err)
-		os.Exit(1)
+		return exitInvalidPRD
 	}
 
 	if !exists {
-		logWarning("No prd.json found in %s", workDir)
+		if ws.name != "" {
+			logError("No PRD found at %s", prdName)
+			return exitInvalidPRD
+		}
+		logWarning("No prd.json found in %s", ws.dir)
 		logInfo("Use the Ralph skill to convert a markdown PRD to prd.json")
 		logInfo("Continuing without PRD...")
 		p = &prd{Project: "unknown", BranchName: "", Description: "No PRD"}
 	} else {
-		logSuccess("Loaded prd.json: project=%s branch=%s", p.Project, p.BranchName)
+		logSuccess("Loaded %s: project=%s branch=%s", prdName, p.Project, p.BranchName)
+	}
+
+	if err := os.MkdirAll(ws.dir, 0755); err != nil {
+		logError("Creating %s: %v", ws.dir, err)
+		return exitError
 	}
 
 	if p.BranchName != "" {
-		if err := archivePreviousRun(workDir, p); err != nil {
+		if err := archivePreviousRun(ws, p); err != nil {
 			logError("Archiving previous run: %v", err)
-			os.Exit(1)
+			return exitError
 		}
 
-		if err := writeLastBranch(workDir, p.BranchName); err != nil {
+		if err := writeLastBranch(ws.dir, p.BranchName); err != nil {
 			logError("Saving branch: %v", err)
-			os.Exit(1)
+			return exitError
 		}
 	}
 
-	if err := initProgressFile(workDir); err != nil {
+	if err := initProgressFile(ws.dir); err != nil {
 		logError("Initializing progress file: %v", err)
-		os.Exit(1)
+		return exitError
+	}
+
+	journal, err := startJournalRun(ws.dir, cfg.tool, p.BranchName)
+	if err != nil {
+		logWarning("Run journal disabled: %v", err)
+		journal = nil
 	}
 
 	printBanner(cfg.tool, cfg.maxIterations, p, version)
-	fmt.Println()
+	blankLine()
 
 	totalStart := time.Now()
+	b := cfg.budget()
+	var spent usage
+	state := newRunState(cfg)
+
+	// Ctrl-C / SIGTERM cancel the context, which kills a running agent, and
+	// so does an abort from the TUI.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, state.cancel = context.WithCancel(ctx)
+	defer state.cancel()
+	interrupted := ctx
+
+	// The duration budget also bounds a running iteration, not just the gaps
+	// between them.
+	if b.maxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, totalStart.Add(b.maxDuration))
+		defer cancel()
+	}
+
+	watchPauseSignal(ctx, state)
+
+	if cfg.listen != "" {
+		srv, err := startAPI(cfg.listen, state)
+		if err != nil {
+			logError("Starting control API: %v", err)
+			return exitError
+		}
+		defer srv.Close()
+	}
+
+	agentOut := agentOutput
+	defer func() { agentOutput = agentOut }()
+	agentOutput = io.MultiWriter(agentOut, state.output)
+
+	var screen *tui
+	if cfg.tui {
+		var err error
+		if screen, err = startTUI(state); err != nil {
+			logError("Starting TUI: %v", err)
+			return exitError
+		}
+		defer screen.stop()
+	}
+
+	// hookEnv describes the run to hook commands.
+	hooks := cfg.hooks
+	hookEnv := func(iteration int, extra ...string) []string {
+		return append([]string{
+			"RALPH_PRD=" + ws.prd,
+			"RALPH_PROJECT=" + p.Project,
+			"RALPH_BRANCH=" + p.BranchName,
+			"RALPH_ITERATION=" + strconv.Itoa(iteration),
+		}, extra...)
+	}
+
+	// finish reports the outcome and returns its exit code. The TUI is shut
+	// down first so the summary lands on the normal screen.
+	finish := func(code int, label, color, message string, details ...string) int {
+		if screen != nil {
+			screen.stop()
+		}
+		state.setPhase("finished", state.iteration)
+		if journal != nil {
+			if err := journal.finish(ws.dir, code); err != nil {
+				logWarning("Saving run journal: %v", err)
+			}
+		}
+		// The run's context may already be cancelled, so hooks get their own.
+		env := hookEnv(state.iteration, "RALPH_EXIT_CODE="+strconv.Itoa(code), "RALPH_OUTCOME="+exitCodeNames[code])
+		if code == exitComplete {
+			if err := runHooks(context.Background(), workDir, "onComplete", hooks.OnComplete, env...); err != nil {
+				logWarning("%v", err)
+			}
+		} else if err := runHooks(context.Background(), workDir, "onFailure", hooks.OnFailure, env...); err != nil {
+			logWarning("%v", err)
+		}
+		notify(cfg.notify, notification{Event: "finish", Outcome: exitCodeNames[code], ExitCode: &code, Message: message,
+			Project: p.Project, Branch: p.BranchName, PRD: ws.prd, Iteration: state.iteration})
+		if code == exitComplete && cfg.archiveOnDone && exists {
+			if err := archiveNow(ws, true); err != nil {
+				logError("Archiving completed run: %v", err)
+			}
+		}
+		printFinish(code, label, color, message, details...)
+		return code
+	}
+
+	// overBudget finishes the run after iteration i if a budget is exceeded.
+	overBudget := func(i int) (int, bool) {
+		elapsed := time.Since(totalStart)
+		tripped := b.exceeded(spent, elapsed)
+		if tripped == "" {
+			return 0, false
+		}
+		return finish(exitBudgetExceeded, "budget", colorWarning,
+			fmt.Sprintf("%s limit reached (%s)", tripped, b.limit(tripped, spent, elapsed)),
+			fmt.Sprintf("$%.2f · %d tokens · %s · %d iterations", spent.costUSD, spent.tokens, elapsed.Round(time.Second), i),
+			"check progress.txt"), true
+	}
+
+	if err := runHooks(ctx, workDir, "preRun", hooks.PreRun, hookEnv(0)...); err != nil {
+		return finish(exitError, "hook", colorError, err.Error())
+	}
+
+	lastPassing := p.passingCount()
+	lastPRD := p
+	sinceProgress := 0
+	failingID, failingCount := "", 0 // the story the last iterations failed to finish
+	stdin := bufio.NewReader(os.Stdin)
+	lastHead := gitHead(workDir)
 
 	for i := 1; i <= cfg.maxIterations; i++ {
-		fmt.Printf("\n  %s%d/%d%s    %s\n", colorAccent, i, cfg.maxIterations, colorReset, progressBar(i-1, cfg.maxIterations, 24))
+		if cfg.step {
+			state.setPhase("paused", i-1)
+			if !confirmStep(stdin, workDir, i, lastHead) {
+				return finish(exitInterrupted, "stopped", colorWarning, fmt.Sprintf("stopped before iteration %d", i))
+			}
+			lastHead = gitHead(workDir)
+		}
 
-		startTime := time.Now()
-		spin := newSpinner(fmt.Sprintf("%srunning %s%s", colorMuted, cfg.tool, colorReset))
-		spin.Start()
-		output, err := runTool(cfg)
-		spin.Stop()
-		elapsed := time.Since(startTime)
+		// Time spent waiting for the step prompt or a resume counts too.
+		if code, over := overBudget(i - 1); over {
+			return code
+		}
+
+		printIterationHeader(i, cfg.maxIterations)
+		state.setPhase("running", i)
+
+		if cfg.compactAfter > 0 {
+			if prog, err := loadProgress(ws.dir); err == nil && len(prog.entries) > cfg.compactAfter {
+				keep := defaultCompactKeep
+				if cfg.keep >= 0 {
+					keep = cfg.keep
+				}
+				if moved, err := compactProgress(ws.dir, keep); err != nil {
+					logWarning("Compacting progress.txt: %v", err)
+				} else if moved > 0 {
+					logInfo("Compacted progress.txt: moved %d older entries to %s", moved, progressArchiveFile)
+				}
+			}
+		}
+
+		before, _ := loadProgress(ws.dir)
+		iterHead := ""
+		if cfg.review != nil {
+			iterHead = gitHead(workDir)
+		}
+
+		// The story the agent should pick, for the iteration hooks and to
+		// notice one that keeps failing.
+		var target *userStory
+		if current, _, err := loadPRDFile(ws.prd); err == nil && current != nil {
+			target = nextStory(current, state.skippedStories())
+		}
+		targetEnv := "RALPH_STORY_ID="
+		if target != nil {
+			targetEnv += target.ID
+		}
+
+		// Transient failures are retried with backoff within the same
+		// iteration, so they don't use up --max-iterations.
+		var (
+			output    string
+			u         usage
+			err       error
+			kind      failureKind
+			reason    string
+			startTime time.Time
+			elapsed   time.Duration
+		)
+		// run numbers every agent run of the iteration for the journal;
+		// attempt counts only the transient retries.
+		for run, attempt, waits := 0, 0, 0; ; run++ {
+			iterCtx, cancelIter := ctx, context.CancelFunc(func() {})
+			if cfg.iterationTimeout > 0 {
+				iterCtx, cancelIter = context.WithTimeout(ctx, cfg.iterationTimeout)
+			}
+			if err := runHooks(ctx, workDir, "preIteration", hooks.PreIteration, hookEnv(i, targetEnv)...); err != nil {
+				cancelIter()
+				if interrupted.Err() != nil {
+					return finish(exitInterrupted, "interrupted", colorWarning, fmt.Sprintf("stopped before iteration %d", i))
+				}
+				return finish(exitError, "hook", colorError, err.Error())
+			}
+			startTime = time.Now()
+			spin := newSpinner(fmt.Sprintf("%srunning %s%s", colorMuted, cfg.tool, colorReset))
+			spin.Start()
+			output, u, err = runTool(iterCtx, cfg, promptFor(cfg)+patternsNote(workDir, ws.root)+skipNote(state.skippedStories()))
+			spin.Stop()
+			timedOut := iterCtx.Err() != nil && ctx.Err() == nil
+			cancelIter()
+			elapsed = time.Since(startTime)
+			spent.add(u)
+			state.addUsage(u)
+			kind, reason = classifyFailure(output, err, timedOut)
+			resetAt, limited := usageLimitReset(output, time.Now())
+			if limited = limited && err != nil && !containsCompletion(output); limited {
+				reason = "usage limit reached"
+			}
+
+			if journal != nil {
+				it := journalIteration{Number: i, Retry: run, StartedAt: startTime, EndedAt: time.Now(), CostUSD: u.costUSD, Tokens: u.tokens, Complete: containsCompletion(output)}
+				if err != nil {
+					it.Error = reason
+				}
+				if err := journal.recordIteration(ws.dir, it, output); err != nil {
+					logWarning("Saving run journal: %v", err)
+				}
+			}
+
+			// postIteration runs after every agent run, even one that was
+			// interrupted, so it gets a context of its own once ctx is done.
+			hookCtx := ctx
+			if ctx.Err() != nil {
+				hookCtx = context.Background()
+			}
+			exitEnv := "RALPH_EXIT_CODE=" + strconv.Itoa(agentExitCode(err))
+			if err := runHooks(hookCtx, workDir, "postIteration", hooks.PostIteration, hookEnv(i, targetEnv, exitEnv)...); err != nil {
+				logWarning("%v", err)
+			}
+
+			// A reached usage limit is waited out rather than retried: the
+			// wait counts against neither --retries nor --max-iterations.
+			// A limit that is still reached after a few waits is treated as
+			// an agent failure, so a CLI that keeps reporting it can't keep
+			// the loop rerunning the agent forever.
+			if limited && waits < maxUsageLimitWaits {
+				waits++
+				blankLine()
+				state.setPhase("waiting", i)
+				if !waitForReset(ctx, state, resetAt) {
+					break
+				}
+				logInfo("Usage limit reset; resuming iteration %d", i)
+				state.setPhase("running", i)
+				continue
+			}
+			if limited {
+				logWarning("Iteration %d: usage limit still reached after %d waits", i, waits)
+			}
+
+			if kind != failureTransient || attempt >= cfg.retries || ctx.Err() != nil || b.exceeded(spent, time.Since(totalStart)) != "" {
+				break
+			}
+			delay := backoff(attempt)
+			blankLine()
+			logWarning("Iteration %d: %s; retrying in %s (retry %d of %d)", i, reason, delay, attempt+1, cfg.retries)
+			state.setPhase("waiting", i)
+			if !sleepCtx(ctx, delay) {
+				break
+			}
+			state.setPhase("running", i)
+			attempt++
+		}
 
 		// Print status on new line after spinner clears
-		fmt.Println()
+		blankLine()
 		if err != nil {
 			printStatusLine(statusLine{id: fmt.Sprintf("iter%d", i), done: false, elapsed: elapsed})
 		} else {
 			printStatusLine(statusLine{id: fmt.Sprintf("iter%d", i), done: true, elapsed: elapsed})
 		}
 
-		if containsCompletion(output) {
-			fmt.Println()
+		if after, err := loadProgress(ws.dir); before != nil && err == nil {
+			if rewritten := progressRewrites(before, after); len(rewritten) > 0 {
+				logWarning("progress.txt is append-only, but the agent rewrote %d earlier entries: %s", len(rewritten), strings.Join(rewritten, "; "))
+			}
+		}
+
+		if interrupted.Err() != nil {
+			return finish(exitInterrupted, "interrupted", colorWarning, fmt.Sprintf("stopped during iteration %d", i))
+		}
+
+		if kind == failureFatal {
+			if errors.Is(err, exec.ErrNotFound) {
+				return finish(exitAgentNotFound, "error", colorError, err.Error())
+			}
+			return finish(exitError, "error", colorError, reason)
+		}
+		if kind == failureTransient {
+			logWarning("Iteration %d failed: %s (out of retries)", i, reason)
+		}
+
+		rejected := false
+		if current, _, err := loadPRDFile(ws.prd); err == nil && current != nil {
+			for _, st := range newlyPassing(lastPRD, current) {
+				if cfg.review != nil {
+					approved, feedback, u, err := reviewStory(ctx, cfg, st, iterHead)
+					spent.add(u)
+					state.addUsage(u)
+					switch {
+					case err != nil:
+						logWarning("Review of %s: %v; keeping it passing", st.ID, err)
+					case !approved:
+						if err := rejectStory(ws.prd, st.ID, i, feedback); err != nil {
+							logWarning("Recording the review of %s: %v", st.ID, err)
+							break
+						}
+						logWarning("Reviewer rejected %s: %s", st.ID, feedback)
+						rejected = true
+						continue
+					default:
+						logInfo("Reviewer approved %s", st.ID)
+					}
+				}
+				logSuccess("Story complete: %s %s", st.ID, st.Title)
+				if err := runHooks(ctx, workDir, "onStoryComplete", hooks.OnStoryComplete, hookEnv(i, "RALPH_STORY_ID="+st.ID, "RALPH_STORY_TITLE="+st.Title)...); err != nil {
+					logWarning("%v", err)
+				}
+				notify(cfg.notify, notification{Event: "story_complete", Message: "story passed",
+					Project: p.Project, Branch: p.BranchName, PRD: ws.prd, Iteration: i, StoryID: st.ID, StoryTitle: st.Title})
+			}
+			lastPRD = current
+			if rejected {
+				// Rejected stories pass again only once the agent fixes them.
+				if reloaded, _, err := loadPRDFile(ws.prd); err == nil && reloaded != nil {
+					lastPRD = reloaded
+				}
+			}
+		}
+
+		if containsCompletion(output) && !rejected {
+			if err := runGates(ctx, workDir, cfg.gates); err != nil {
+				return finish(exitGateFailed, "gate", colorError, err.Error())
+			}
 			totalElapsed := time.Since(totalStart)
-			fmt.Printf("  %scomplete%s  finished in %d iterations\n", colorSuccess, colorReset, i)
-			fmt.Printf("  %s          %s%s\n\n", colorMuted, totalElapsed.Round(time.Second), colorReset)
-			os.Exit(0)
+			return finish(exitComplete, "complete", colorSuccess, fmt.Sprintf("finished in %d iterations", i),
+				totalElapsed.Round(time.Second).String())
+		}
+
+		if code, over := overBudget(i); over {
+			return code
+		}
+
+		if cfg.stallAfter > 0 && exists {
+			if current, _, err := loadPRDFile(ws.prd); err == nil && current != nil && current.passingCount() > lastPassing {
+				lastPassing = current.passingCount()
+				sinceProgress = 0
+			} else {
+				sinceProgress++
+			}
+			if sinceProgress >= cfg.stallAfter {
+				return finish(exitStalled, "stalled", colorWarning, fmt.Sprintf("no story passed in the last %d iterations", sinceProgress),
+					"check progress.txt")
+			}
+		}
+
+		if cfg.splitAfter > 0 && target != nil {
+			current, _, _ := loadPRDFile(ws.prd)
+			st := findStory(current, target.ID)
+			switch {
+			case st == nil || st.Passes:
+				failingID, failingCount = "", 0
+			case st.ID != failingID:
+				failingID, failingCount = st.ID, 1
+			default:
+				failingCount++
+			}
+			if failingCount >= cfg.splitAfter && i < cfg.maxIterations {
+				logWarning("Story %s failed %d iterations in a row; asking %s to split it", st.ID, failingCount, cfg.tool)
+				parts, u, err := splitStory(ctx, cfg, *st, failingCount)
+				spent.add(u)
+				state.addUsage(u)
+				if err != nil {
+					logWarning("Splitting %s: %v", st.ID, err)
+				} else {
+					ids := make([]string, len(parts))
+					for k, part := range parts {
+						ids[k] = part.ID
+					}
+					logSuccess("Split %s into %s", st.ID, strings.Join(ids, ", "))
+					sinceProgress = 0
+				}
+				failingID, failingCount = "", 0
+				// The split spends too.
+				if code, over := overBudget(i); over {
+					return code
+				}
+			}
 		}
 
 		if i < cfg.maxIterations {
+			if state.pauseRequested() {
+				state.setPhase("paused", i)
+				logInfo("Paused after iteration %d (run 'ralph resume', send SIGUSR1, or press p in the TUI)", i)
+				for state.pauseRequested() && interrupted.Err() == nil && b.exceeded(spent, time.Since(totalStart)) == "" {
+					time.Sleep(200 * time.Millisecond)
+				}
+				if interrupted.Err() != nil {
+					return finish(exitInterrupted, "interrupted", colorWarning, fmt.Sprintf("stopped while paused after iteration %d", i))
+				}
+				if !state.pauseRequested() {
+					logInfo("Resuming")
+				}
+			}
+
+			state.setPhase("waiting", i)
 			spin := newSpinner(fmt.Sprintf("%swaiting%s", colorMuted, colorReset))
 			spin.Start()
-			time.Sleep(2 * time.Second)
+			time.Sleep(iterationPause)
 			spin.Stop()
-			fmt.Println()
+			blankLine()
 		}
 	}
 
-	fmt.Println()
 	totalElapsed := time.Since(totalStart)
-	fmt.Printf("  %stimeout%s   max iterations reached (%d)\n", colorWarning, colorReset, cfg.maxIterations)
-	fmt.Printf("  %s          %s%s\n", colorMuted, totalElapsed.Round(time.Second), colorReset)
-	fmt.Printf("  %s          check progress.txt%s\n\n", colorMuted, colorReset)
-	os.Exit(1)
+	return finish(exitMaxIterations, "timeout", colorWarning, fmt.Sprintf("max iterations reached (%d)", cfg.maxIterations),
+		totalElapsed.Round(time.Second).String(), "check progress.txt")
 }
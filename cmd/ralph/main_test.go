@@ -1,9 +1,22 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestParseArgs(t *testing.T) {
@@ -219,7 +232,7 @@ func TestArchiveDetection(t *testing.T) {
 		os.WriteFile(filepath.Join(tmpDir, "prd.json"), []byte(`{}`), 0644)
 
 		p := &prd{BranchName: "main"}
-		err := archivePreviousRun(tmpDir, p)
+		err := archivePreviousRun(defaultWorkspace(tmpDir), p)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -235,7 +248,7 @@ func TestArchiveDetection(t *testing.T) {
 		os.WriteFile(filepath.Join(tmpDir, "prd.json"), []byte(`{}`), 0644)
 
 		p := &prd{BranchName: "new-branch"}
-		err := archivePreviousRun(tmpDir, p)
+		err := archivePreviousRun(defaultWorkspace(tmpDir), p)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -253,7 +266,7 @@ func TestArchiveDetection(t *testing.T) {
 		os.WriteFile(filepath.Join(tmpDir, "progress.txt"), []byte("old progress"), 0644)
 
 		p := &prd{BranchName: "new-branch"}
-		err := archivePreviousRun(tmpDir, p)
+		err := archivePreviousRun(defaultWorkspace(tmpDir), p)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -302,3 +315,1573 @@ func TestContainsCompletion(t *testing.T) {
 		})
 	}
 }
+
+func TestParseArgsBudgets(t *testing.T) {
+	cfg, err := parseArgs([]string{"--max-cost", "$2.50", "--max-tokens=100000", "--max-duration", "90m"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.maxCost != 2.5 {
+		t.Errorf("maxCost = %v, want 2.5", cfg.maxCost)
+	}
+	if cfg.maxTokens != 100000 {
+		t.Errorf("maxTokens = %d, want 100000", cfg.maxTokens)
+	}
+	if cfg.maxDuration != 90*time.Minute {
+		t.Errorf("maxDuration = %v, want 90m", cfg.maxDuration)
+	}
+	if _, err := parseArgs([]string{"--tool", "amp", "--max-duration", "1h"}); err != nil {
+		t.Errorf("--max-duration with amp: unexpected error: %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"--max-cost", "lots"},
+		{"--max-tokens", "-5"},
+		{"--max-duration", "10"},
+		{"--max-cost"},
+		{"--tool", "amp", "--max-cost", "5"},
+		{"--max-tokens", "1000", "--tool=amp"},
+	} {
+		if _, err := parseArgs(args); err == nil {
+			t.Errorf("parseArgs(%q): expected error", args)
+		}
+	}
+}
+
+func TestBudgetExceeded(t *testing.T) {
+	b := budget{maxCost: 1, maxTokens: 1000, maxDuration: time.Hour}
+	tests := []struct {
+		name    string
+		spent   usage
+		elapsed time.Duration
+		want    string
+	}{
+		{"within limits", usage{costUSD: 0.5, tokens: 10}, time.Minute, ""},
+		{"cost", usage{costUSD: 1.2}, time.Minute, "cost"},
+		{"tokens", usage{tokens: 1000}, time.Minute, "tokens"},
+		{"duration", usage{}, 2 * time.Hour, "duration"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := b.exceeded(tt.spent, tt.elapsed); got != tt.want {
+				t.Errorf("exceeded = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := (budget{}).exceeded(usage{costUSD: 100, tokens: 1e9}, 100*time.Hour); got != "" {
+		t.Errorf("zero budget should be unlimited, got %q", got)
+	}
+}
+
+func TestParseClaudeResult(t *testing.T) {
+	raw := `{"type":"result","result":"done <promise>COMPLETE</promise>","total_cost_usd":0.42,` +
+		`"usage":{"input_tokens":10,"output_tokens":20,"cache_creation_input_tokens":30,"cache_read_input_tokens":40}}`
+	text, u, ok := parseClaudeResult(raw)
+	if !ok {
+		t.Fatal("expected ok")
+	}
+	if !containsCompletion(text) {
+		t.Errorf("result text lost: %q", text)
+	}
+	if u.costUSD != 0.42 || u.tokens != 100 {
+		t.Errorf("usage = %+v, want cost 0.42 tokens 100", u)
+	}
+
+	if _, _, ok := parseClaudeResult("Error: not logged in"); ok {
+		t.Error("expected ok=false for non-JSON output")
+	}
+}
+
+func TestClaudeStream(t *testing.T) {
+	var out bytes.Buffer
+	s := &claudeStream{out: &out}
+	events := `{"type":"system","subtype":"init"}
+{"type":"assistant","message":{"content":[{"type":"text","text":"Reading prd.json"},{"type":"tool_use","name":"Bash"}]}}
+{"type":"user","message":{"content":[{"type":"tool_result"}]}}
+{"type":"result","result":"done <promise>COMPLETE</promise>","total_cost_usd":0.5,"usage":{"output_tokens":7}}`
+	// Writes split mid-line, as pipe reads do.
+	fmt.Fprint(s, events[:40])
+	if out.Len() != 0 {
+		t.Errorf("partial line written: %q", out.String())
+	}
+	userAt := strings.Index(events, `{"type":"user"`)
+	fmt.Fprint(s, events[40:userAt])
+	if !strings.Contains(out.String(), "Reading prd.json\n→ Bash\n") {
+		t.Errorf("assistant text not streamed: %q", out.String())
+	}
+	fmt.Fprint(s, events[userAt:])
+	s.flush()
+	if !s.done || !s.streamed || !containsCompletion(s.result) || s.usage.costUSD != 0.5 || s.usage.tokens != 7 {
+		t.Errorf("stream = %+v", s)
+	}
+
+	s = &claudeStream{out: &out}
+	fmt.Fprint(s, "Invalid API key · Please run /login\n")
+	if s.done || s.raw.String() != "Invalid API key · Please run /login\n" {
+		t.Errorf("non-JSON line: done=%v raw=%q", s.done, s.raw.String())
+	}
+}
+
+// fakeAgent puts an executable named tool on PATH that runs script, and
+// returns a working directory for the run.
+func fakeAgent(t *testing.T, tool, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake agent scripts need a POSIX shell")
+	}
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, tool), []byte("#!/bin/sh\ncat >/dev/null\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	pause, retry := iterationPause, retryBackoff
+	iterationPause, retryBackoff = 0, 0
+	t.Cleanup(func() { iterationPause, retryBackoff = pause, retry })
+	return t.TempDir()
+}
+
+func TestBudgetWhilePaused(t *testing.T) {
+	workDir := fakeAgent(t, "claude", `touch .ralph-pause; echo '{"result":"still working"}'`)
+	os.WriteFile(filepath.Join(workDir, "prd.json"), []byte(`{"project":"demo","branchName":"ralph/pause"}`), 0644)
+	hookLog := filepath.Join(workDir, "hooks.log")
+
+	cfg := &config{tool: "claude", maxIterations: 3, maxDuration: 500 * time.Millisecond, workDir: workDir,
+		hooks: projectHooks{PreIteration: []string{"echo pre >> " + hookLog}}}
+	if got := runLoop(cfg); got != exitBudgetExceeded {
+		t.Fatalf("runLoop = %d, want %d", got, exitBudgetExceeded)
+	}
+	if data, _ := os.ReadFile(hookLog); string(data) != "pre\n" {
+		t.Errorf("preIteration ran %q, want once", data)
+	}
+	j, _ := loadJournal(workDir)
+	if its := j.current().Iterations; len(its) != 1 {
+		t.Errorf("journal iterations = %d, want 1", len(its))
+	}
+}
+
+func TestRunLoopExitCodes(t *testing.T) {
+	const complete = `echo '{"result":"<promise>COMPLETE</promise>","total_cost_usd":0.01}'`
+	const working = `echo '{"result":"still working","total_cost_usd":1,"usage":{"output_tokens":50}}'`
+
+	tests := []struct {
+		name   string
+		script string
+		prd    string
+		cfg    config
+		want   int
+	}{
+		{"complete", complete, "", config{maxIterations: 3}, exitComplete},
+		{"max iterations", working, "", config{maxIterations: 2}, exitMaxIterations},
+		{"cost budget", working, "", config{maxIterations: 5, maxCost: 2}, exitBudgetExceeded},
+		{"stalled", working, `{"userStories":[{"id":"US-001"}]}`, config{maxIterations: 5, stallAfter: 2}, exitStalled},
+		{"invalid prd", complete, `{invalid`, config{maxIterations: 1}, exitInvalidPRD},
+		{"gate failed", complete, "", config{maxIterations: 1, gates: []string{"exit 1"}}, exitGateFailed},
+		{"gate passed", complete, "", config{maxIterations: 1, gates: []string{"true"}}, exitComplete},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.tool = "claude"
+			cfg.workDir = fakeAgent(t, "claude", tt.script)
+			if tt.prd != "" {
+				os.WriteFile(filepath.Join(cfg.workDir, "prd.json"), []byte(tt.prd), 0644)
+			}
+			if got := runLoop(&cfg); got != tt.want {
+				t.Errorf("runLoop = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("agent not found", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+		cfg := config{tool: "claude", maxIterations: 1, workDir: t.TempDir()}
+		if got := runLoop(&cfg); got != exitAgentNotFound {
+			t.Errorf("runLoop = %d, want %d", got, exitAgentNotFound)
+		}
+	})
+}
+
+func TestParseArgsOutput(t *testing.T) {
+	tests := []struct {
+		args      []string
+		wantMode  string
+		wantQuiet bool
+	}{
+		{[]string{}, outputText, false},
+		{[]string{"--output", "plain"}, outputPlain, false},
+		{[]string{"--output=json"}, outputJSON, false},
+		{[]string{"--json", "-q"}, outputJSON, true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseArgs(tt.args)
+		if err != nil {
+			t.Fatalf("parseArgs(%q): %v", tt.args, err)
+		}
+		if cfg.output != tt.wantMode || cfg.quiet != tt.wantQuiet {
+			t.Errorf("parseArgs(%q) = %q/%v, want %q/%v", tt.args, cfg.output, cfg.quiet, tt.wantMode, tt.wantQuiet)
+		}
+	}
+
+	if _, err := parseArgs([]string{"--output", "xml"}); err == nil {
+		t.Error("expected error for unknown output mode")
+	}
+}
+
+// captureOutput returns what fn writes through the ui output functions.
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+	var buf bytes.Buffer
+	orig := uiOut
+	uiOut = &buf
+	defer func() { uiOut = orig }()
+
+	fn()
+	return buf.String()
+}
+
+func TestJSONOutput(t *testing.T) {
+	outMode = outputJSON
+	defer func() { outMode = outputText }()
+
+	got := captureOutput(t, func() {
+		logInfo("hello %s", "world")
+		blankLine()
+		printStatusLine(statusLine{id: "iter1", done: true, elapsed: time.Second})
+		printFinish(exitStalled, "stalled", colorWarning, "no progress")
+	})
+
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 JSON lines, got %d: %q", len(lines), got)
+	}
+	var events []map[string]any
+	for _, line := range lines {
+		var ev map[string]any
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		events = append(events, ev)
+	}
+	if events[0]["event"] != "log" || events[0]["message"] != "hello world" {
+		t.Errorf("log event = %v", events[0])
+	}
+	if events[1]["event"] != "status" || events[1]["done"] != true {
+		t.Errorf("status event = %v", events[1])
+	}
+	if events[2]["outcome"] != "stalled" || events[2]["exit_code"] != float64(exitStalled) {
+		t.Errorf("finish event = %v", events[2])
+	}
+}
+
+func TestQuietOutput(t *testing.T) {
+	outMode, outQuiet = outputPlain, true
+	defer func() { outMode, outQuiet = outputText, false }()
+
+	p := &prd{Project: "Demo", BranchName: "ralph/demo", UserStories: []userStory{{ID: "US-001", Title: "First"}}}
+	got := captureOutput(t, func() {
+		printBanner("claude", 10, p, "1.0.0")
+		printIterationHeader(1, 10)
+		logInfo("working")
+		blankLine()
+		printStatusLine(statusLine{id: "iter1", done: true, elapsed: time.Second})
+		logWarning("careful")
+		printFinish(exitStalled, "stalled", colorWarning, "no progress")
+	})
+
+	for _, unwanted := range []string{"Demo", "US-001", "1/10", "working", "iter1"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("quiet output contains %q:\n%s", unwanted, got)
+		}
+	}
+	for _, wanted := range []string{"careful", "no progress"} {
+		if !strings.Contains(got, wanted) {
+			t.Errorf("quiet output is missing %q:\n%s", wanted, got)
+		}
+	}
+}
+
+func TestLineBuffer(t *testing.T) {
+	b := newLineBuffer(3)
+	fmt.Fprint(b, "one\ntwo\r\nthree\nfour\nfi")
+	fmt.Fprint(b, "ve")
+
+	got := b.tail(10)
+	want := []string{"two", "three", "four", "five"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("tail = %q, want %q", got, want)
+	}
+	if got := b.tail(1); len(got) != 1 || got[0] != "five" {
+		t.Errorf("tail(1) = %q, want [five]", got)
+	}
+}
+
+func TestSkipStory(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "prd.json"), []byte(`{"userStories":[
+		{"id":"US-001","priority":1,"passes":true},
+		{"id":"US-002","priority":3},
+		{"id":"US-003","priority":2}
+	]}`), 0644)
+
+	state := newRunState(&config{workDir: tmpDir, maxIterations: 5})
+	if id := state.skip(""); id != "US-003" {
+		t.Errorf("skip() = %q, want next story US-003", id)
+	}
+	if id := state.skip(""); id != "US-002" {
+		t.Errorf("second skip() = %q, want US-002", id)
+	}
+	if id := state.skip(""); id != "" {
+		t.Errorf("skip() with nothing left = %q, want empty", id)
+	}
+
+	note := skipNote(state.skippedStories())
+	if !strings.Contains(note, "- US-003\n- US-002") {
+		t.Errorf("skipNote missing stories: %q", note)
+	}
+	if skipNote(nil) != "" {
+		t.Error("skipNote(nil) should be empty")
+	}
+}
+
+func TestTruncateANSI(t *testing.T) {
+	s := "\033[1mhello\033[0m world"
+	if got := stripANSI(truncateANSI(s, 7)); got != "hello w" {
+		t.Errorf("truncateANSI visible = %q, want %q", got, "hello w")
+	}
+	if got := truncateANSI(s, 40); got != s {
+		t.Errorf("short string changed: %q", got)
+	}
+}
+
+func TestPauseControl(t *testing.T) {
+	tmpDir := t.TempDir()
+	state := newRunState(&config{workDir: tmpDir})
+
+	if state.pauseRequested() {
+		t.Fatal("new state should not be paused")
+	}
+	if err := writePauseFile(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	if !state.pauseRequested() {
+		t.Error("pause file should request a pause")
+	}
+	if state.togglePause() {
+		t.Error("toggling with a pause file present should resume")
+	}
+	if pauseFileExists(tmpDir) || state.pauseRequested() {
+		t.Error("resume should remove the pause file")
+	}
+	if !state.togglePause() || !state.pauseRequested() {
+		t.Error("toggle should request a pause")
+	}
+	if err := removePauseFile(tmpDir); err != nil {
+		t.Errorf("removing a missing pause file: %v", err)
+	}
+}
+
+func TestConfirmStep(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"\n", true},
+		{"y\n", true},
+		{"q\n", false},
+		{"x\n\n", true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		captureOutput(t, func() {
+			if got := confirmStep(bufio.NewReader(strings.NewReader(tt.input)), t.TempDir(), 1, ""); got != tt.want {
+				t.Errorf("confirmStep(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArchiveCommands(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, a := range []struct{ name, prd string }{
+		{"2025-01-01-old", `{"project":"p","branchName":"ralph/old","userStories":[{"id":"US-001","passes":true}]}`},
+		{"2025-02-01-mid", `{"project":"p","branchName":"ralph/mid"}`},
+		{"2025-03-01-new", `{"project":"p","branchName":"ralph/new","userStories":[{"id":"US-001"},{"id":"US-002","passes":true}]}`},
+	} {
+		dir := filepath.Join(tmpDir, "archive", a.name)
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, "prd.json"), []byte(a.prd), 0644)
+		os.WriteFile(filepath.Join(dir, "progress.txt"), []byte("## entry for "+a.name+"\n"), 0644)
+	}
+
+	entries, err := listArchives(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].name != "2025-03-01-new" || entries[2].name != "2025-01-01-old" {
+		t.Fatalf("listArchives order = %v", entries)
+	}
+	if entries[0].prd.passingCount() != 1 {
+		t.Errorf("passing = %d, want 1", entries[0].prd.passingCount())
+	}
+
+	t.Run("restore archives current run first", func(t *testing.T) {
+		os.WriteFile(filepath.Join(tmpDir, "prd.json"), []byte(`{"branchName":"ralph/current"}`), 0644)
+		if err := archiveRestore(defaultWorkspace(tmpDir), "2025-01-01-old"); err != nil {
+			t.Fatal(err)
+		}
+		p, _, _ := loadPRD(tmpDir)
+		if p.BranchName != "ralph/old" {
+			t.Errorf("restored branch = %q, want ralph/old", p.BranchName)
+		}
+		if got := readLastBranch(tmpDir); got != "ralph/old" {
+			t.Errorf(".ralph-branch = %q, want ralph/old", got)
+		}
+		data, _ := os.ReadFile(filepath.Join(tmpDir, "progress.txt"))
+		if !strings.Contains(string(data), "2025-01-01-old") {
+			t.Errorf("progress not restored: %q", data)
+		}
+		entries, _ := listArchives(tmpDir)
+		if len(entries) != 4 {
+			t.Errorf("expected current run to be archived, got %d archives", len(entries))
+		}
+	})
+
+	t.Run("unknown archive", func(t *testing.T) {
+		if err := archiveRestore(defaultWorkspace(tmpDir), "nope"); err == nil {
+			t.Error("expected error for unknown archive")
+		}
+	})
+
+	t.Run("prune keeps newest", func(t *testing.T) {
+		if err := archivePrune(tmpDir, 2); err != nil {
+			t.Fatal(err)
+		}
+		entries, _ := listArchives(tmpDir)
+		if len(entries) != 2 {
+			t.Fatalf("expected 2 archives after prune, got %d", len(entries))
+		}
+		if _, err := os.Stat(filepath.Join(tmpDir, "archive", "2025-01-01-old")); !os.IsNotExist(err) {
+			t.Error("oldest archive should have been pruned")
+		}
+	})
+}
+
+func TestArchiveRunMeta(t *testing.T) {
+	workDir := fakeAgent(t, "claude", `echo '{"result":"working","total_cost_usd":0.25,"usage":{"output_tokens":10}}'`)
+	os.WriteFile(filepath.Join(workDir, "prd.json"), []byte(`{"project":"demo","branchName":"ralph/feature"}`), 0644)
+
+	cfg := config{tool: "claude", maxIterations: 2, workDir: workDir}
+	if got := runLoop(&cfg); got != exitMaxIterations {
+		t.Fatalf("runLoop = %d, want %d", got, exitMaxIterations)
+	}
+
+	first, err := archiveRun(defaultWorkspace(workDir), "ralph/feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := archiveRun(defaultWorkspace(workDir), "ralph/feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatalf("archiving the same branch twice reused folder %s", first)
+	}
+	if !strings.HasSuffix(second, "-feature-2") {
+		t.Errorf("second archive = %s, want -2 suffix", second)
+	}
+
+	meta := loadArchiveMeta(first)
+	if meta == nil {
+		t.Fatal("meta.json missing")
+	}
+	if meta.Project != "demo" || meta.Tool != "claude" || meta.RalphVersion != version {
+		t.Errorf("meta = %+v", meta)
+	}
+	if meta.Runs != 1 || meta.Iterations != 2 || meta.CostUSD != 0.5 || meta.Tokens != 20 {
+		t.Errorf("meta counts: runs=%d iterations=%d cost=%v tokens=%d", meta.Runs, meta.Iterations, meta.CostUSD, meta.Tokens)
+	}
+	if len(meta.Journal) != 1 || meta.Journal[0].Outcome != "max_iterations" {
+		t.Errorf("journal = %+v", meta.Journal)
+	}
+	if len(meta.Transcripts) != 2 {
+		t.Fatalf("transcripts = %v, want 2", meta.Transcripts)
+	}
+	data, err := os.ReadFile(filepath.Join(first, filepath.FromSlash(meta.Transcripts[0])))
+	if err != nil || !strings.Contains(string(data), "working") {
+		t.Errorf("transcript content = %q, err %v", data, err)
+	}
+
+	t.Run("retries are not iterations", func(t *testing.T) {
+		stateDir, folder := t.TempDir(), t.TempDir()
+		j := &runJournal{Runs: []journalRun{{Iterations: []journalIteration{
+			{Number: 1, Error: "transient API error: 529"},
+			{Number: 1, Retry: 1},
+			{Number: 2, Error: "usage limit reached"},
+			{Number: 2, Retry: 1, Error: "exit status 1"},
+			{Number: 3},
+		}}}}
+		if err := j.save(stateDir); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeArchiveMeta(stateDir, folder, "ralph/feature"); err != nil {
+			t.Fatal(err)
+		}
+		if meta := loadArchiveMeta(folder); meta == nil || meta.Iterations != 3 || meta.FailedIterations != 1 {
+			t.Errorf("meta = %+v, want 3 iterations, 1 failed", meta)
+		}
+	})
+}
+
+func TestArchiveOnComplete(t *testing.T) {
+	workDir := fakeAgent(t, "claude", `echo '{"result":"<promise>COMPLETE</promise>"}'`)
+	os.WriteFile(filepath.Join(workDir, "prd.json"), []byte(`{"project":"demo","branchName":"ralph/done"}`), 0644)
+
+	cfg := config{tool: "claude", maxIterations: 1, workDir: workDir, archiveOnDone: true}
+	if got := runLoop(&cfg); got != exitComplete {
+		t.Fatalf("runLoop = %d, want %d", got, exitComplete)
+	}
+
+	for _, f := range []string{"prd.json", "progress.txt", ".ralph-branch", filepath.Join(".ralph", "journal.json")} {
+		if _, err := os.Stat(filepath.Join(workDir, f)); !os.IsNotExist(err) {
+			t.Errorf("%s should have been reset", f)
+		}
+	}
+	entries, _ := listArchives(workDir)
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].name, "-done") {
+		t.Fatalf("archives = %v, want one for ralph/done", entries)
+	}
+	if m := entries[0].meta; m == nil || len(m.Journal) != 1 || m.Journal[0].Outcome != "complete" {
+		t.Errorf("archived journal should record the completed run: %+v", m)
+	}
+
+	if err := archiveNow(defaultWorkspace(workDir), false); err == nil {
+		t.Error("archiveNow without prd.json should fail")
+	}
+}
+
+func TestStateDir(t *testing.T) {
+	workDir := t.TempDir()
+
+	t.Run("defaults to the repository root", func(t *testing.T) {
+		cfg := &config{workDir: workDir}
+		applyProjectConfig(cfg, &projectConfig{})
+		if cfg.stateDir != workDir {
+			t.Errorf("stateDir = %q, want %q", cfg.stateDir, workDir)
+		}
+		if promptFor(cfg) != claudePrompt {
+			t.Error("prompt should be unchanged for the default state dir")
+		}
+	})
+
+	t.Run("flag overrides project config", func(t *testing.T) {
+		cfg, _ := parseArgs([]string{"--state-dir", "flagdir"})
+		cfg.workDir = workDir
+		applyProjectConfig(cfg, &projectConfig{StateDir: "configdir"})
+		if cfg.stateDir != filepath.Join(workDir, "flagdir") {
+			t.Errorf("stateDir = %q, want flagdir", cfg.stateDir)
+		}
+	})
+
+	t.Run("migrate moves files and saves config", func(t *testing.T) {
+		os.WriteFile(filepath.Join(workDir, "prd.json"), []byte(`{"branchName":"ralph/x"}`), 0644)
+		os.WriteFile(filepath.Join(workDir, "progress.txt"), []byte("log"), 0644)
+		writeLastBranch(workDir, "ralph/x")
+		os.MkdirAll(filepath.Join(workDir, "archive", "2025-01-01-old"), 0755)
+		j, _ := startJournalRun(workDir, "claude", "ralph/x")
+		j.finish(workDir, exitComplete)
+
+		cfg := &config{workDir: workDir}
+		applyProjectConfig(cfg, &projectConfig{StateDir: ".ralph/state"})
+		if err := migrateState(cfg); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, f := range []string{"prd.json", "progress.txt", ".ralph-branch", "archive", filepath.Join(".ralph", "journal.json")} {
+			if _, err := os.Stat(filepath.Join(workDir, f)); !os.IsNotExist(err) {
+				t.Errorf("%s still in repo root", f)
+			}
+			if _, err := os.Stat(filepath.Join(cfg.stateDir, f)); err != nil {
+				t.Errorf("%s not moved: %v", f, err)
+			}
+		}
+		pc, _ := loadProjectConfig(workDir)
+		if pc.StateDir != ".ralph/state" {
+			t.Errorf("saved stateDir = %q", pc.StateDir)
+		}
+		if p := promptFor(cfg); !strings.Contains(p, "Read the PRD at .ralph/state/prd.json") || !strings.Contains(p, ".ralph/state/progress.txt") {
+			t.Error("prompt should reference the state dir paths")
+		}
+	})
+
+	t.Run("migrate to root fails", func(t *testing.T) {
+		cfg := &config{workDir: workDir}
+		applyProjectConfig(cfg, &projectConfig{})
+		if err := migrateState(cfg); err == nil {
+			t.Error("expected error when state dir is the root")
+		}
+	})
+}
+
+func TestMultiplePRDs(t *testing.T) {
+	workDir := fakeAgent(t, "claude", `echo '{"result":"working"}'`)
+	os.WriteFile(filepath.Join(workDir, "prd.json"), []byte(`{"project":"main","branchName":"ralph/main"}`), 0644)
+	os.MkdirAll(filepath.Join(workDir, "prds"), 0755)
+	os.WriteFile(filepath.Join(workDir, "prds", "auth.json"), []byte(`{"project":"auth","branchName":"ralph/auth","userStories":[{"id":"US-001","passes":true},{"id":"US-002"}]}`), 0644)
+
+	cfg, err := parseArgs([]string{"run", "--prd", "auth", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.workDir = workDir
+	applyProjectConfig(cfg, &projectConfig{})
+	authDir := filepath.Join(workDir, "prds", "auth")
+	if cfg.ws.prd != filepath.Join(workDir, "prds", "auth.json") || cfg.ws.dir != authDir {
+		t.Fatalf("workspace = %+v", cfg.ws)
+	}
+	if p := promptFor(cfg); !strings.Contains(p, "prds/auth.json") || !strings.Contains(p, "prds/auth/progress.txt") {
+		t.Error("prompt should reference the named PRD and its progress file")
+	}
+
+	if got := runLoop(cfg); got != exitMaxIterations {
+		t.Fatalf("runLoop = %d, want %d", got, exitMaxIterations)
+	}
+	for _, f := range []string{"progress.txt", ".ralph-branch", filepath.Join(".ralph", "journal.json")} {
+		if _, err := os.Stat(filepath.Join(authDir, f)); err != nil {
+			t.Errorf("%s not kept in prds/auth: %v", f, err)
+		}
+		if _, err := os.Stat(filepath.Join(workDir, f)); !os.IsNotExist(err) {
+			t.Errorf("%s written to the default workspace", f)
+		}
+	}
+
+	if err := archiveNow(cfg.ws, false); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := listArchives(authDir); len(entries) != 1 || entries[0].prd.Project != "auth" {
+		t.Errorf("auth archives = %v", entries)
+	}
+	if entries, _ := listArchives(workDir); len(entries) != 0 {
+		t.Errorf("default archive should be untouched, got %v", entries)
+	}
+
+	list, err := listWorkspaces(workDir)
+	if err != nil || len(list) != 2 || list[0].name != "" || list[1].name != "auth" {
+		t.Fatalf("listWorkspaces = %+v, %v", list, err)
+	}
+	out := captureOutput(t, func() { listPRDs(workDir) })
+	if !strings.Contains(out, "ralph/auth") || !strings.Contains(out, "1/2") || !strings.Contains(out, "max_iterations") {
+		t.Errorf("list output = %q", out)
+	}
+
+	t.Run("missing named PRD", func(t *testing.T) {
+		cfg := &config{tool: "claude", maxIterations: 1, workDir: workDir, prd: "prds/nope.json"}
+		applyProjectConfig(cfg, &projectConfig{})
+		if got := runLoop(cfg); got != exitInvalidPRD {
+			t.Errorf("runLoop = %d, want %d", got, exitInvalidPRD)
+		}
+	})
+}
+
+func TestParseProgress(t *testing.T) {
+	data := `# Ralph Progress Log
+Started: Mon, 01 Jan 2025 10:00:00 UTC
+---
+## Codebase Patterns
+- Use sql<number> for aggregations
+- Migrations need IF NOT EXISTS
+
+## 2025-01-01 10:15 - US-001
+- Added the status column
+- **Learnings for future iterations:**
+  - Run migrations with make migrate
+  - The admin UI is in web/admin
+---
+## 2025-01-01 11:00 - US-002
+- Wired up the filter
+---
+`
+	prog := parseProgress(data)
+	if len(prog.patterns) != 2 || prog.patterns[0] != "- Use sql<number> for aggregations" {
+		t.Errorf("patterns = %q", prog.patterns)
+	}
+	if len(prog.entries) != 2 {
+		t.Fatalf("entries = %d, want 2", len(prog.entries))
+	}
+	e := prog.entries[0]
+	if e.storyID != "US-001" || e.time != "2025-01-01 10:15" {
+		t.Errorf("entry = %q / %q", e.storyID, e.time)
+	}
+	if len(e.learnings) != 2 || e.learnings[1] != "The admin UI is in web/admin" {
+		t.Errorf("learnings = %q", e.learnings)
+	}
+	for heading, want := range map[string]string{
+		"2025-01-01 12:00 - US-003a":         "US-003a",
+		"2025-01-01 12:30 - US-003a1":        "US-003a1",
+		"2025-01-01 13:00 - US-004 (split)":  "US-004",
+		"2025-01-01 13:30 - fixed the build": "",
+	} {
+		if got := newProgressEntry(heading).storyID; got != want {
+			t.Errorf("story id of %q = %q, want %q", heading, got, want)
+		}
+	}
+
+	t.Run("appending and editing patterns is fine", func(t *testing.T) {
+		after := strings.Replace(data, "- Migrations", "- New pattern\n- Migrations", 1) + "## 2025-01-01 12:00 - US-003\n- More\n---\n"
+		if got := progressRewrites(prog, parseProgress(after)); len(got) != 0 {
+			t.Errorf("progressRewrites = %q, want none", got)
+		}
+	})
+
+	t.Run("rewriting an entry is reported", func(t *testing.T) {
+		after := strings.Replace(data, "Wired up the filter", "Rewrote history", 1)
+		if got := progressRewrites(prog, parseProgress(after)); len(got) != 1 || !strings.Contains(got[0], "US-002") {
+			t.Errorf("progressRewrites = %q, want the US-002 entry", got)
+		}
+		truncated := data[:strings.Index(data, "## 2025-01-01 11:00")]
+		if got := progressRewrites(prog, parseProgress(truncated)); len(got) != 1 {
+			t.Errorf("removed entry not reported: %q", got)
+		}
+	})
+
+	t.Run("tail", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "progress.txt"), []byte(data), 0644)
+		cfg := &config{command: "progress", args: []string{"tail", "1"}, ws: defaultWorkspace(dir)}
+		var code int
+		out := captureOutput(t, func() { code = runProgressCommand(cfg) })
+		if code != 0 || !strings.Contains(out, "US-002") || strings.Contains(out, "US-001") {
+			t.Errorf("tail 1 = %d, %q", code, out)
+		}
+		if got := recentProgress(dir, 4); len(got) != 2 || got[0] != "2025-01-01 10:15 - US-001" {
+			t.Errorf("recentProgress = %q", got)
+		}
+	})
+}
+
+func TestCompactProgress(t *testing.T) {
+	dir := t.TempDir()
+	var sb strings.Builder
+	sb.WriteString("# Ralph Progress Log\nStarted: today\n---\n## Codebase Patterns\n- Keep me\n\n")
+	for i := 1; i <= 8; i++ {
+		fmt.Fprintf(&sb, "## 2025-01-0%d - US-00%d\n- did %d\n---\n", i, i, i)
+	}
+	os.WriteFile(filepath.Join(dir, "progress.txt"), []byte(sb.String()), 0644)
+
+	moved, err := compactProgress(dir, 3)
+	if err != nil || moved != 5 {
+		t.Fatalf("compactProgress = %d, %v; want 5 moved", moved, err)
+	}
+	prog, _ := loadProgress(dir)
+	if len(prog.entries) != 3 || prog.entries[0].storyID != "US-006" {
+		t.Errorf("kept entries = %+v", prog.entries)
+	}
+	if len(prog.patterns) != 1 || prog.patterns[0] != "- Keep me" {
+		t.Errorf("patterns not preserved: %q", prog.patterns)
+	}
+	older, _ := os.ReadFile(filepath.Join(dir, progressArchiveFile))
+	if !strings.Contains(string(older), "US-001") || !strings.Contains(string(older), "US-005") || strings.Contains(string(older), "US-006") {
+		t.Errorf("archive = %q", older)
+	}
+
+	// Compacting again adds to the archive and keeps a running count.
+	os.WriteFile(filepath.Join(dir, "progress.txt"), []byte(prog.String()+"## 2025-01-09 - US-009\n- did 9\n---\n"), 0644)
+	if moved, _ := compactProgress(dir, 3); moved != 1 {
+		t.Errorf("second compaction moved %d, want 1", moved)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "progress.txt"))
+	if !strings.Contains(string(data), "Compacted: 6 older entries") || strings.Count(string(data), "Compacted:") != 1 {
+		t.Errorf("progress.txt header = %q", data)
+	}
+	if moved, _ := compactProgress(dir, 3); moved != 0 {
+		t.Errorf("nothing left to compact, moved %d", moved)
+	}
+
+	t.Run("kept entries are not reflowed", func(t *testing.T) {
+		dir := t.TempDir()
+		patterns := "## Codebase Patterns\n- First\n\n- Second, after a blank line\n"
+		kept := "## 2025-01-02 - US-002\n- Changed the config loader\n\n```yaml\nkey: a\n---\nkey: b\n```\n\nSecond paragraph."
+		os.WriteFile(filepath.Join(dir, "progress.txt"), []byte("# Ralph Progress Log\n---\n"+patterns+"\n## 2025-01-01 - US-001\n- did 1\n---\n"+kept+"\n---\n"), 0644)
+		if moved, err := compactProgress(dir, 1); err != nil || moved != 1 {
+			t.Fatalf("compactProgress = %d, %v", moved, err)
+		}
+		data, _ := os.ReadFile(filepath.Join(dir, "progress.txt"))
+		if !strings.Contains(string(data), patterns) || !strings.Contains(string(data), kept+"\n---\n") {
+			t.Errorf("progress.txt after compaction:\n%s", data)
+		}
+	})
+}
+
+func TestPatternsCarryOver(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "prd.json"), []byte(`{"branchName":"ralph/first"}`), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "progress.txt"), []byte("## Codebase Patterns\n- Use sqlc for queries\n- Tests live next to code\n\n## 2025 - US-001\n- done\n---\n"), 0644)
+	writeLastBranch(tmpDir, "ralph/first")
+
+	if err := archivePreviousRun(defaultWorkspace(tmpDir), &prd{BranchName: "ralph/second"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadPatterns(tmpDir); len(got) != 2 || got[0] != "- Use sqlc for queries" {
+		t.Fatalf("patterns after archive = %q", got)
+	}
+
+	added, err := mergePatterns(tmpDir, []string{"-  use SQLC for queries", "* Prefer table tests", ""})
+	if err != nil || added != 1 {
+		t.Errorf("mergePatterns added %d, %v; want 1", added, err)
+	}
+	if got := loadPatterns(tmpDir); len(got) != 3 || got[2] != "- Prefer table tests" {
+		t.Errorf("merged patterns = %q", got)
+	}
+
+	note := patternsNote(tmpDir, tmpDir)
+	if !strings.Contains(note, "- Use sqlc for queries\n- Tests live next to code\n- Prefer table tests") || !strings.Contains(note, "(.ralph/patterns.md)") {
+		t.Errorf("patternsNote = %q", note)
+	}
+	if note := patternsNote(filepath.Dir(tmpDir), tmpDir); !strings.Contains(note, "("+filepath.Base(tmpDir)+"/.ralph/patterns.md)") {
+		t.Errorf("patternsNote with a state directory = %q", note)
+	}
+	if patternsNote(t.TempDir(), t.TempDir()) != "" {
+		t.Error("patternsNote without saved patterns should be empty")
+	}
+}
+
+func TestControlAPI(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "prd.json"), []byte(`{"project":"demo","branchName":"ralph/api","userStories":[
+		{"id":"US-001","priority":1,"passes":true},
+		{"id":"US-002","priority":2},
+		{"id":"US-003","priority":3}
+	]}`), 0644)
+	state := newRunState(&config{tool: "claude", workDir: tmpDir, maxIterations: 5})
+	srv := httptest.NewServer(newAPIHandler(state))
+	defer srv.Close()
+
+	post := func(path string) *http.Response {
+		t.Helper()
+		resp, err := http.Post(srv.URL+path, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	var status apiStatus
+	resp, err := http.Get(srv.URL + "/api/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&status)
+	resp.Body.Close()
+	if status.Project != "demo" || status.Passing != 1 || status.Total != 3 || status.MaxIterations != 5 {
+		t.Errorf("status = %+v", status)
+	}
+
+	captureOutput(t, func() {
+		post("/api/pause").Body.Close()
+		if !state.pauseRequested() {
+			t.Error("pause did not reach the run state")
+		}
+		post("/api/resume").Body.Close()
+		if state.pauseRequested() {
+			t.Error("resume did not reach the run state")
+		}
+		post("/api/skip?id=US-003").Body.Close()
+		if resp := post("/api/skip"); resp.StatusCode != http.StatusOK {
+			t.Errorf("skip next = %d", resp.StatusCode)
+		}
+		if resp := post("/api/skip"); resp.StatusCode != http.StatusConflict {
+			t.Errorf("skip with nothing left = %d, want 409", resp.StatusCode)
+		}
+	})
+
+	for origin, want := range map[string]int{"http://evil.example": http.StatusForbidden, "null": http.StatusForbidden, srv.URL: http.StatusOK} {
+		req, _ := http.NewRequest("POST", srv.URL+"/api/pause", strings.NewReader("x=1"))
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		var resp *http.Response
+		captureOutput(t, func() { resp, err = http.DefaultClient.Do(req) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("pause from origin %s = %d, want %d", origin, resp.StatusCode, want)
+		}
+		if paused := state.pauseRequested(); paused != (want == http.StatusOK) {
+			t.Errorf("pause from origin %s: pause requested = %v", origin, paused)
+		}
+		state.setPause(false)
+	}
+
+	guarded := loopbackHost("127.0.0.1:7777", newAPIHandler(state))
+	for host, want := range map[string]int{"rebind.example:7777": http.StatusForbidden, "localhost:7777": http.StatusOK, "[::1]:7777": http.StatusOK} {
+		req := httptest.NewRequest("GET", "/api/status", nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		guarded.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("status with Host %s = %d, want %d", host, rec.Code, want)
+		}
+	}
+
+	var stories []apiStory
+	resp, _ = http.Get(srv.URL + "/api/stories")
+	json.NewDecoder(resp.Body).Decode(&stories)
+	resp.Body.Close()
+	if len(stories) != 3 || stories[0].Status != "passing" || stories[1].Status != "skipped" || stories[2].Status != "skipped" {
+		t.Errorf("stories = %+v", stories)
+	}
+
+	t.Run("output stream", func(t *testing.T) {
+		fmt.Fprintln(state.output, "before")
+		resp, err := http.Get(srv.URL + "/api/output?tail=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		fmt.Fprintln(state.output, "\033[1mafter\033[0m")
+		r := bufio.NewReader(resp.Body)
+		var got []string
+		for len(got) < 2 {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+				got = append(got, data)
+			}
+		}
+		if got[0] != "before" || got[1] != "after" {
+			t.Errorf("stream = %q", got)
+		}
+	})
+}
+
+func TestServeDashboard(t *testing.T) {
+	workDir := fakeAgent(t, "claude", `echo '{"result":"transcript text"}'`)
+	os.WriteFile(filepath.Join(workDir, "prd.json"), []byte(`{"project":"demo","branchName":"ralph/serve"}`), 0644)
+	cfg := config{tool: "claude", maxIterations: 1, workDir: workDir}
+	runLoop(&cfg)
+	if _, err := archiveRun(defaultWorkspace(workDir), "ralph/serve"); err != nil {
+		t.Fatal(err)
+	}
+
+	api := httptest.NewServer(newAPIHandler(newRunState(&config{tool: "claude", workDir: workDir})))
+	defer api.Close()
+	srv := httptest.NewServer(newServeHandler(defaultWorkspace(workDir), strings.TrimPrefix(api.URL, "http://")))
+	defer srv.Close()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var buf bytes.Buffer
+		buf.ReadFrom(resp.Body)
+		return resp.StatusCode, buf.String()
+	}
+
+	if code, body := get("/"); code != 200 || !strings.Contains(body, "<title>ralph</title>") {
+		t.Errorf("dashboard = %d", code)
+	}
+	if code, body := get("/api/status"); code != 200 || !strings.Contains(body, `"project":"demo"`) {
+		t.Errorf("proxied status = %d %s", code, body)
+	}
+	req, _ := http.NewRequest("POST", srv.URL+"/api/resume", nil)
+	req.Header.Set("Origin", srv.URL)
+	var resp *http.Response
+	var err error
+	captureOutput(t, func() { resp, err = http.DefaultClient.Do(req) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("proxied resume from the dashboard = %d", resp.StatusCode)
+	}
+
+	var j runJournal
+	_, body := get("/data/journal")
+	json.Unmarshal([]byte(body), &j)
+	if len(j.Runs) != 1 || len(j.Runs[0].Iterations) != 1 {
+		t.Fatalf("journal = %s", body)
+	}
+	transcript := strings.TrimPrefix(j.Runs[0].Iterations[0].Transcript, "transcripts/")
+	if code, body := get("/data/transcripts/" + transcript); code != 200 || !strings.Contains(body, "transcript text") {
+		t.Errorf("transcript = %d %q", code, body)
+	}
+
+	var archives []archiveSummary
+	_, body = get("/data/archives")
+	json.Unmarshal([]byte(body), &archives)
+	if len(archives) != 1 || archives[0].Project != "demo" || archives[0].Iterations != 1 {
+		t.Fatalf("archives = %s", body)
+	}
+	if code, body := get("/data/archives/" + archives[0].Name + "/transcripts/" + transcript); code != 200 || !strings.Contains(body, "transcript text") {
+		t.Errorf("archived transcript = %d %q", code, body)
+	}
+	if code, _ := get("/data/archives/nope"); code != 404 {
+		t.Errorf("unknown archive = %d, want 404", code)
+	}
+	if code, _ := get("/data/transcripts/..%2Fjournal.json"); code == 200 {
+		t.Error("transcript path escaped the transcripts folder")
+	}
+
+	api.Close()
+	if code, body := get("/api/status"); code != http.StatusBadGateway || !strings.Contains(body, "no run listening") {
+		t.Errorf("status with no run = %d %s", code, body)
+	}
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	workDir := fakeAgent(t, "claude", `sed 's/"passes":false/"passes":true/' prd.json > prd.tmp && mv prd.tmp prd.json
+echo '{"result":"<promise>COMPLETE</promise>"}'`)
+	os.WriteFile(filepath.Join(workDir, "prd.json"), []byte(`{"project":"demo","branchName":"ralph/hooks","userStories":[{"id":"US-001","title":"First","passes":false}]}`), 0644)
+	hookLog := filepath.Join(workDir, "hooks.log")
+	record := `echo "$RALPH_HOOK iter=$RALPH_ITERATION story=$RALPH_STORY_ID exit=$RALPH_EXIT_CODE" >> ` + hookLog
+
+	cfg := &config{tool: "claude", maxIterations: 3, workDir: workDir, hooks: projectHooks{
+		PreRun:          []string{record},
+		PreIteration:    []string{record},
+		PostIteration:   []string{record},
+		OnStoryComplete: []string{record},
+		OnComplete:      []string{record},
+		OnFailure:       []string{record},
+	}}
+	if got := runLoop(cfg); got != exitComplete {
+		t.Fatalf("runLoop = %d, want %d", got, exitComplete)
+	}
+	data, _ := os.ReadFile(hookLog)
+	want := "preRun iter=0 story= exit=\n" +
+		"preIteration iter=1 story=US-001 exit=\n" +
+		"postIteration iter=1 story=US-001 exit=0\n" +
+		"onStoryComplete iter=1 story=US-001 exit=\n" +
+		"onComplete iter=1 story= exit=0\n"
+	if string(data) != want {
+		t.Errorf("hooks ran:\n%s\nwant:\n%s", data, want)
+	}
+
+	t.Run("failing preRun stops the run", func(t *testing.T) {
+		os.Remove(hookLog)
+		cfg := &config{tool: "claude", maxIterations: 3, workDir: workDir, hooks: projectHooks{
+			PreRun:    []string{"exit 3"},
+			OnFailure: []string{record},
+		}}
+		if got := runLoop(cfg); got != exitError {
+			t.Fatalf("runLoop = %d, want %d", got, exitError)
+		}
+		data, _ := os.ReadFile(hookLog)
+		if string(data) != "onFailure iter=0 story= exit=1\n" {
+			t.Errorf("hooks ran: %q", data)
+		}
+	})
+
+	t.Run("output goes with the agent's", func(t *testing.T) {
+		var out bytes.Buffer
+		defer func(w io.Writer) { agentOutput = w }(agentOutput)
+		agentOutput = &out
+		captureOutput(t, func() {
+			if err := runHooks(context.Background(), workDir, "preRun", []string{"echo hook-out"}); err != nil {
+				t.Error(err)
+			}
+			if err := runGates(context.Background(), workDir, []string{"echo gate-out >&2"}); err != nil {
+				t.Error(err)
+			}
+		})
+		if out.String() != "hook-out\ngate-out\n" {
+			t.Errorf("agent output = %q", out.String())
+		}
+	})
+
+	t.Run("iteration hooks run around each attempt", func(t *testing.T) {
+		workDir := fakeAgent(t, "claude", `n=$(cat attempts 2>/dev/null || echo 0); echo $((n+1)) > attempts
+if [ "$n" -lt 1 ]; then echo "API Error: 529 overloaded" >&2; exit 1; fi
+exit 2`)
+		os.WriteFile(filepath.Join(workDir, "prd.json"), []byte(`{"project":"demo","branchName":"ralph/hooks","userStories":[{"id":"US-002","title":"Second","passes":false}]}`), 0644)
+		hookLog := filepath.Join(workDir, "hooks.log")
+		record := `echo "$RALPH_HOOK iter=$RALPH_ITERATION story=$RALPH_STORY_ID exit=$RALPH_EXIT_CODE" >> ` + hookLog
+		cfg := &config{tool: "claude", maxIterations: 1, retries: 1, workDir: workDir, hooks: projectHooks{
+			PreIteration:  []string{record},
+			PostIteration: []string{record},
+		}}
+		if got := runLoop(cfg); got != exitMaxIterations {
+			t.Fatalf("runLoop = %d, want %d", got, exitMaxIterations)
+		}
+		data, _ := os.ReadFile(hookLog)
+		want := "preIteration iter=1 story=US-002 exit=\n" +
+			"postIteration iter=1 story=US-002 exit=1\n" +
+			"preIteration iter=1 story=US-002 exit=\n" +
+			"postIteration iter=1 story=US-002 exit=2\n"
+		if string(data) != want {
+			t.Errorf("hooks ran:\n%s\nwant:\n%s", data, want)
+		}
+	})
+}
+
+func TestNotifySinks(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string][]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		buf.ReadFrom(r.Body)
+		mu.Lock()
+		received[r.URL.Path] = append(received[r.URL.Path], buf.String())
+		mu.Unlock()
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	workDir := fakeAgent(t, "claude", `sed 's/"passes":false/"passes":true/' prd.json > prd.tmp && mv prd.tmp prd.json
+echo '{"result":"<promise>COMPLETE</promise>"}'`)
+	os.WriteFile(filepath.Join(workDir, "prd.json"), []byte(`{"project":"demo","branchName":"ralph/notify","userStories":[{"id":"US-001","title":"First","passes":false}]}`), 0644)
+	cmdLog := filepath.Join(workDir, "notify.log")
+
+	cfg := &config{tool: "claude", maxIterations: 2, workDir: workDir, notify: []notifySink{
+		{Type: "webhook", URL: srv.URL + "/hook"},
+		{Type: "slack", URL: srv.URL + "/slack", Events: []string{"complete"}},
+		{Type: "webhook", URL: srv.URL + "/stalls", Events: []string{"stalled"}},
+		{Type: "webhook", URL: srv.URL + "/broken"},
+		{Type: "command", Command: `echo "$RALPH_EVENT $RALPH_EXIT_CODE $RALPH_MESSAGE" >> ` + cmdLog},
+	}}
+	if got := runLoop(cfg); got != exitComplete {
+		t.Fatalf("runLoop = %d, want %d (a failing sink must not fail the run)", got, exitComplete)
+	}
+
+	hooks := received["/hook"]
+	if len(hooks) != 2 {
+		t.Fatalf("webhook got %d notifications, want story_complete and finish", len(hooks))
+	}
+	var story, done notification
+	json.Unmarshal([]byte(hooks[0]), &story)
+	json.Unmarshal([]byte(hooks[1]), &done)
+	if story.Event != "story_complete" || story.StoryID != "US-001" || story.Project != "demo" {
+		t.Errorf("story notification = %s", hooks[0])
+	}
+	if done.Event != "finish" || done.Outcome != "complete" || done.ExitCode == nil || *done.ExitCode != 0 {
+		t.Errorf("finish notification = %s", hooks[1])
+	}
+
+	if slack := received["/slack"]; len(slack) != 1 || !strings.Contains(slack[0], `"text":"ralph: demo complete`) {
+		t.Errorf("slack = %q", slack)
+	}
+	if len(received["/stalls"]) != 0 {
+		t.Errorf("filtered sink fired: %q", received["/stalls"])
+	}
+	data, _ := os.ReadFile(cmdLog)
+	if !strings.Contains(string(data), "story_complete  ralph: US-001 First passed") || !strings.Contains(string(data), "finish 0 ralph: demo complete") {
+		t.Errorf("command sink ran with: %q", data)
+	}
+
+	t.Run("a hanging command times out", func(t *testing.T) {
+		defer func(d time.Duration) { notifyTimeout = d }(notifyTimeout)
+		notifyTimeout = 100 * time.Millisecond
+		start := time.Now()
+		err := notifySink{Type: "command", Command: "sleep 30"}.send(notification{Event: "finish"})
+		if err == nil || time.Since(start) > 5*time.Second {
+			t.Errorf("send = %v after %s, want a timeout", err, time.Since(start))
+		}
+	})
+}
+
+func TestClassifyFailure(t *testing.T) {
+	exitErr := fmt.Errorf("exit status 1")
+	tests := []struct {
+		name     string
+		output   string
+		err      error
+		timedOut bool
+		want     failureKind
+	}{
+		{"clean exit", "done", nil, false, failureNone},
+		{"overloaded", "API Error: 529 {\"type\":\"overloaded_error\"}", exitErr, false, failureTransient},
+		{"rate limited", "Error: rate limit exceeded", exitErr, false, failureTransient},
+		{"timeout", "", exitErr, true, failureTransient},
+		{"not logged in", "Invalid API key · Please run /login", exitErr, false, failureFatal},
+		{"missing binary", "", fmt.Errorf("start: %w", exec.ErrNotFound), false, failureFatal},
+		{"plain failure", "tests failed", exitErr, false, failureAgent},
+		{"rate limit far up in the output", "implemented the rate limit middleware" + strings.Repeat("x", 3000), exitErr, false, failureAgent},
+	}
+	for _, tt := range tests {
+		if got, _ := classifyFailure(tt.output, tt.err, tt.timedOut); got != tt.want {
+			t.Errorf("%s: classifyFailure = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	retryBackoff, maxRetryBackoff = time.Second, 5*time.Second
+	defer func() { retryBackoff, maxRetryBackoff = 10*time.Second, 5*time.Minute }()
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestRetryTransientFailures(t *testing.T) {
+	workDir := fakeAgent(t, "claude", `n=$(cat attempts 2>/dev/null || echo 0); echo $((n+1)) > attempts
+if [ "$n" -lt 2 ]; then echo "API Error: 529 overloaded" >&2; exit 1; fi
+echo '{"result":"<promise>COMPLETE</promise>"}'`)
+	os.WriteFile(filepath.Join(workDir, "prd.json"), []byte(`{"project":"demo","branchName":"ralph/retry"}`), 0644)
+
+	cfg := &config{tool: "claude", maxIterations: 1, retries: 3, workDir: workDir}
+	if got := runLoop(cfg); got != exitComplete {
+		t.Fatalf("runLoop = %d, want %d after two retries", got, exitComplete)
+	}
+	j, _ := loadJournal(workDir)
+	its := j.current().Iterations
+	if len(its) != 3 || its[0].Retry != 0 || its[2].Retry != 2 || its[2].Number != 1 || !strings.Contains(its[0].Error, "529") {
+		t.Errorf("journal iterations = %+v", its)
+	}
+	if len(its) == 3 && (!strings.HasSuffix(its[0].Transcript, "-iter01.txt") || !strings.HasSuffix(its[2].Transcript, "-iter01-r2.txt")) {
+		t.Errorf("transcripts = %s, %s", its[0].Transcript, its[2].Transcript)
+	}
+
+	t.Run("out of retries moves on", func(t *testing.T) {
+		os.WriteFile(filepath.Join(workDir, "attempts"), []byte("-10"), 0644)
+		cfg := &config{tool: "claude", maxIterations: 1, retries: 1, workDir: workDir}
+		if got := runLoop(cfg); got != exitMaxIterations {
+			t.Errorf("runLoop = %d, want %d", got, exitMaxIterations)
+		}
+	})
+
+	t.Run("fatal fails fast", func(t *testing.T) {
+		workDir := fakeAgent(t, "claude", `echo "Invalid API key · Please run /login"; exit 1`)
+		cfg := &config{tool: "claude", maxIterations: 5, retries: 3, workDir: workDir}
+		if got := runLoop(cfg); got != exitError {
+			t.Errorf("runLoop = %d, want %d", got, exitError)
+		}
+		j, _ := loadJournal(workDir)
+		if n := len(j.current().Iterations); n != 1 {
+			t.Errorf("fatal failure ran %d times, want 1", n)
+		}
+	})
+}
+
+func TestUsageLimitReset(t *testing.T) {
+	now := time.Date(2025, 6, 1, 14, 10, 0, 0, time.UTC)
+	tests := []struct {
+		output  string
+		limited bool
+		want    time.Time
+	}{
+		{"all good", false, time.Time{}},
+		{"Claude AI usage limit reached|1748790000", true, time.Unix(1748790000, 0)},
+		{"5-hour limit reached ∙ resets 3pm", true, time.Date(2025, 6, 1, 15, 0, 0, 0, time.UTC)},
+		{"Your limit will reset at 1:30pm", true, time.Date(2025, 6, 2, 13, 30, 0, 0, time.UTC)},
+		{"Claude usage limit reached. Your limit will reset at 12am (UTC).", true, time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)},
+		{"usage limit reached, try again later", true, time.Time{}},
+		{"Context limit reached · /compact or /clear to continue", false, time.Time{}},
+		{"API Error: Claude's response exceeded the 32000 output token maximum. Output token limit reached.", false, time.Time{}},
+		{"Rate limit reached for requests", false, time.Time{}},
+	}
+	for _, tt := range tests {
+		got, limited := usageLimitReset(tt.output, now)
+		if limited != tt.limited || !got.Equal(tt.want) {
+			t.Errorf("usageLimitReset(%q) = %v, %v; want %v, %v", tt.output, got, limited, tt.want, tt.limited)
+		}
+	}
+}
+
+func TestUsageLimitWait(t *testing.T) {
+	// The reset time is already past, so the fallback wait applies.
+	defer func(d time.Duration) { usageLimitFallback = d }(usageLimitFallback)
+	usageLimitFallback = 0
+	reset := time.Now().Add(-2 * time.Minute).Unix()
+	workDir := fakeAgent(t, "claude", fmt.Sprintf(`n=$(cat attempts 2>/dev/null || echo 0); echo $((n+1)) > attempts
+if [ "$n" -lt 2 ]; then echo "Claude AI usage limit reached|%d"; exit 1; fi
+echo '{"result":"<promise>COMPLETE</promise>"}'`, reset))
+
+	cfg := &config{tool: "claude", maxIterations: 1, retries: 0, workDir: workDir}
+	if got := runLoop(cfg); got != exitComplete {
+		t.Fatalf("runLoop = %d, want %d after waiting out the usage limit", got, exitComplete)
+	}
+	j, _ := loadJournal(workDir)
+	if its := j.current().Iterations; len(its) != 3 || its[0].Error != "usage limit reached" || its[2].Retry != 2 {
+		t.Errorf("journal iterations = %+v", its)
+	}
+
+	t.Run("waits are capped", func(t *testing.T) {
+		os.WriteFile(filepath.Join(workDir, "attempts"), []byte("-100"), 0644)
+		cfg := &config{tool: "claude", maxIterations: 1, retries: 0, workDir: workDir}
+		if got := runLoop(cfg); got != exitMaxIterations {
+			t.Fatalf("runLoop = %d, want %d", got, exitMaxIterations)
+		}
+		j, _ := loadJournal(workDir)
+		if its := j.current().Iterations; len(its) != maxUsageLimitWaits+1 {
+			t.Errorf("agent ran %d times, want %d", len(its), maxUsageLimitWaits+1)
+		}
+	})
+}
+
+func TestDoctor(t *testing.T) {
+	workDir := fakeAgent(t, "claude", `echo "1.2.3 (Claude Code)"`)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.MkdirAll(filepath.Join(home, ".claude", "skills", "ralph-prd"), 0755)
+	os.WriteFile(filepath.Join(home, ".claude", "skills", "ralph-prd", "SKILL.md"), []byte(skillPRD+"\n"), 0644)
+	os.WriteFile(filepath.Join(workDir, "CLAUDE.md"), []byte("# Claude\n"), 0644)
+	os.WriteFile(filepath.Join(workDir, "prd.json"), []byte(`{"project":"demo","branchName":"ralph/demo","userStories":[{"id":"US-001","title":"A"},{"id":"US-001","title":"B"}]}`), 0644)
+
+	cfg := &config{tool: "claude", workDir: workDir, ws: defaultWorkspace(workDir), gates: []string{"no-such-gate-tool --all"}}
+	status := map[string]string{}
+	for _, c := range doctorChecks(cfg) {
+		status[c.name] = c.status
+	}
+	want := map[string]string{
+		"agent":        checkPass,
+		"instructions": checkPass,
+		"prd":          checkFail, // duplicate id
+		"skill prd":    checkPass,
+		"skill ralph":  checkWarn, // not installed
+		"gate":         checkWarn,
+	}
+	if _, err := exec.LookPath("git"); err == nil {
+		want["git"] = checkFail // not a repository
+	}
+	for name, w := range want {
+		if status[name] != w {
+			t.Errorf("check %q = %q, want %q (all: %v)", name, status[name], w, status)
+		}
+	}
+	if got := runDoctor(cfg); got != exitError {
+		t.Errorf("runDoctor = %d, want %d", got, exitError)
+	}
+}
+
+func TestInitInstructions(t *testing.T) {
+	workDir := t.TempDir()
+	os.WriteFile(filepath.Join(workDir, "go.mod"), []byte("module example.com/demo\n"), 0644)
+	os.WriteFile(filepath.Join(workDir, "package.json"), []byte(`{"scripts":{"build":"tsc","test":"vitest"}}`), 0644)
+	os.WriteFile(filepath.Join(workDir, "pnpm-lock.yaml"), nil, 0644)
+
+	projects := detectProjects(workDir)
+	if len(projects) != 2 || projects[0].language != "Go" || projects[1].language != "JavaScript" {
+		t.Fatalf("detectProjects = %+v", projects)
+	}
+	if got := projects[1].checks(); len(got) != 1 || got[0] != "pnpm run test" {
+		t.Errorf("node checks = %v", got)
+	}
+
+	cfg := &config{tool: "amp", workDir: workDir}
+	if findInstructions(workDir, "amp") != "" {
+		t.Fatal("found instructions in an empty repository")
+	}
+	if err := initInstructions(cfg); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(workDir, "AGENTS.md"))
+	for _, want := range []string{"- Go (go.mod)", "- Test: `go test ./...`", "- Build: `pnpm run build`", "## Conventions"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("AGENTS.md missing %q:\n%s", want, data)
+		}
+	}
+	if findInstructions(workDir, "claude") != "" {
+		t.Error("claude should not read AGENTS.md without CLAUDE.md")
+	}
+
+	os.WriteFile(filepath.Join(workDir, "CLAUDE.md"), []byte("# Notes\n"), 0644)
+	cfg = &config{tool: "claude", workDir: workDir, sync: true}
+	for i := 0; i < 2; i++ {
+		if err := initInstructions(cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, _ = os.ReadFile(filepath.Join(workDir, "CLAUDE.md"))
+	if string(data) != "# Notes\n\n@AGENTS.md\n" {
+		t.Errorf("CLAUDE.md = %q, want the import added once", data)
+	}
+}
+
+func TestInit(t *testing.T) {
+	workDir := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	os.WriteFile(filepath.Join(workDir, "Cargo.toml"), []byte("[package]\nname = \"demo\"\n"), 0644)
+
+	// Keep the proposed gates, put state in ralph/, name the branch, accept
+	// the instructions file and install the skills.
+	cfg := &config{tool: "claude", workDir: workDir, stateDir: workDir}
+	if err := runInit(cfg, strings.NewReader("\nralph\nralph/first\n\ny\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	pc, err := loadProjectConfig(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pc.StateDir != "ralph" || strings.Join(pc.Gates, "|") != "cargo clippy -- -D warnings|cargo test" {
+		t.Errorf("project config = %+v", pc)
+	}
+	p, exists, err := loadPRDFile(filepath.Join(workDir, "ralph", "prd.json"))
+	if err != nil || !exists || p.BranchName != "ralph/first" || p.UserStories == nil {
+		t.Errorf("prd skeleton = %+v, %v, %v", p, exists, err)
+	}
+	if !fileExists(filepath.Join(workDir, "CLAUDE.md")) {
+		t.Error("CLAUDE.md not created")
+	}
+	if !fileExists(skillPath(os.Getenv("HOME"), "ralph")) {
+		t.Error("skills not installed")
+	}
+
+	// The saved gates apply to later commands.
+	cfg = &config{workDir: workDir}
+	applyProjectConfig(cfg, pc)
+	if len(cfg.gates) != 2 {
+		t.Errorf("gates from project config = %v", cfg.gates)
+	}
+
+	t.Run("absolute state directory", func(t *testing.T) {
+		workDir, stateDir := t.TempDir(), t.TempDir()
+		cfg := &config{tool: "amp", workDir: workDir, stateDir: workDir}
+		if err := runInit(cfg, strings.NewReader("none\n"+stateDir+"\n\nn\n")); err != nil {
+			t.Fatal(err)
+		}
+		pc, _ := loadProjectConfig(workDir)
+		later := &config{workDir: workDir}
+		applyProjectConfig(later, pc)
+		if later.stateDir != stateDir || !fileExists(filepath.Join(stateDir, "prd.json")) {
+			t.Errorf("state dir = %s (saved %q); prd.json in %s: %v", later.stateDir, pc.StateDir, stateDir, fileExists(filepath.Join(stateDir, "prd.json")))
+		}
+	})
+
+	t.Run("yes takes the defaults", func(t *testing.T) {
+		workDir := t.TempDir()
+		cfg := &config{tool: "amp", workDir: workDir, stateDir: workDir, yes: true}
+		if err := runInit(cfg, strings.NewReader("")); err != nil {
+			t.Fatal(err)
+		}
+		if !fileExists(filepath.Join(workDir, "prd.json")) || !fileExists(filepath.Join(workDir, "AGENTS.md")) {
+			t.Error("prd.json and AGENTS.md not created")
+		}
+		if pc, _ := loadProjectConfig(workDir); pc.StateDir != "" || len(pc.Gates) != 0 {
+			t.Errorf("project config = %+v", pc)
+		}
+	})
+}
+
+func TestPlan(t *testing.T) {
+	const stories = `[{"id":"US-001","title":"Add setting","priority":1,"acceptanceCriteria":["Typecheck passes"]},{"id":"US-002","title":"Apply theme","priority":2}]`
+	workDir := fakeAgent(t, "claude", `n=$(cat calls 2>/dev/null || echo 0); echo $((n+1)) > calls
+if [ "$n" = 0 ]; then mkdir -p tasks; echo "# PRD: Dark mode" > tasks/prd-add-dark-mode.md
+else echo '{"project":"demo","branchName":"ralph/dark-mode","userStories":`+stories+`}' > prd.json; fi
+echo '{"result":"done","total_cost_usd":0.25}'`)
+
+	cfg := &config{tool: "claude", workDir: workDir, ws: defaultWorkspace(workDir), args: []string{"Add", "dark", "mode!"}}
+	if got := runPlan(cfg, strings.NewReader("n\n")); got != 0 {
+		t.Fatalf("runPlan = %d, want 0", got)
+	}
+	p, _, err := loadPRDFile(filepath.Join(workDir, "prd.json"))
+	if err != nil || p == nil || len(p.UserStories) != 2 {
+		t.Fatalf("prd.json = %+v, %v", p, err)
+	}
+
+	t.Run("existing stories are kept unless confirmed", func(t *testing.T) {
+		if got := runPlan(cfg, strings.NewReader("\n")); got != exitError {
+			t.Errorf("runPlan = %d, want %d", got, exitError)
+		}
+		if data, _ := os.ReadFile(filepath.Join(workDir, "calls")); strings.TrimSpace(string(data)) != "2" {
+			t.Errorf("agent ran again: calls = %s", data)
+		}
+	})
+
+	t.Run("invalid stories are rejected", func(t *testing.T) {
+		workDir := fakeAgent(t, "claude", `mkdir -p tasks; echo "# PRD" > tasks/prd-x.md
+echo '{"project":"demo","userStories":[{"id":"US-001","title":"A"},{"id":"US-001","title":"B"}]}' > prd.json`)
+		cfg := &config{tool: "claude", workDir: workDir, ws: defaultWorkspace(workDir), args: []string{"x"}}
+		if got := runPlan(cfg, strings.NewReader("")); got != exitInvalidPRD {
+			t.Errorf("runPlan = %d, want %d", got, exitInvalidPRD)
+		}
+	})
+}
+
+func TestSplitStory(t *testing.T) {
+	workDir := fakeAgent(t, "amp", `n=$(cat calls 2>/dev/null || echo 0); echo $((n+1)) > calls
+case $n in
+2) echo '<stories>[{"title":"Schema","acceptanceCriteria":["Migration runs"]},{"title":"UI","notes":"after schema"}]</stories>' ;;
+3) echo '<promise>COMPLETE</promise>' ;;
+*) echo 'still working' ;;
+esac`)
+	os.WriteFile(filepath.Join(workDir, "prd.json"), []byte(`{"project":"demo","branchName":"ralph/split","userStories":[
+{"id":"US-001","title":"Done","priority":1,"passes":true},
+{"id":"US-002","title":"Too big","priority":2},
+{"id":"US-003","title":"Later","priority":3}]}`), 0644)
+
+	cfg := &config{tool: "amp", maxIterations: 5, splitAfter: 2, workDir: workDir}
+	if got := runLoop(cfg); got != exitComplete {
+		t.Fatalf("runLoop = %d, want %d", got, exitComplete)
+	}
+	p, _, _ := loadPRD(workDir)
+	var ids []string
+	for _, s := range p.UserStories {
+		ids = append(ids, s.ID)
+	}
+	if strings.Join(ids, " ") != "US-001 US-002a US-002b US-003" {
+		t.Fatalf("stories after split = %v", ids)
+	}
+	if s := p.UserStories[2]; s.Title != "UI" || s.Priority != 2 || s.Notes != "Split from US-002. after schema" {
+		t.Errorf("split story = %+v", s)
+	}
+	prog, _ := os.ReadFile(filepath.Join(workDir, "progress.txt"))
+	if !strings.Contains(string(prog), "US-002 (split)") || !strings.Contains(string(prog), "US-002b: UI") {
+		t.Errorf("progress.txt does not record the split:\n%s", prog)
+	}
+
+	t.Run("split spend counts against the budget", func(t *testing.T) {
+		workDir := fakeAgent(t, "claude", `n=$(cat calls 2>/dev/null || echo 0); echo $((n+1)) > calls
+case $n in
+2) echo '{"result":"<stories>[{\"title\":\"A\"},{\"title\":\"B\"}]</stories>","total_cost_usd":0.4}' ;;
+*) echo '{"result":"still working","total_cost_usd":0.4}' ;;
+esac`)
+		os.WriteFile(filepath.Join(workDir, "prd.json"), []byte(`{"project":"demo","branchName":"ralph/split","userStories":[{"id":"US-001","title":"Too big","priority":1}]}`), 0644)
+
+		cfg := &config{tool: "claude", maxIterations: 5, splitAfter: 2, maxCost: 1, workDir: workDir}
+		if got := runLoop(cfg); got != exitBudgetExceeded {
+			t.Fatalf("runLoop = %d, want %d", got, exitBudgetExceeded)
+		}
+		if data, _ := os.ReadFile(filepath.Join(workDir, "calls")); strings.TrimSpace(string(data)) != "3" {
+			t.Errorf("agent calls = %s, want 2 iterations and the split", data)
+		}
+	})
+
+	if got := subStoryID("US-002a", 1); got != "US-002a2" {
+		t.Errorf("subStoryID = %q", got)
+	}
+	if _, err := parseSplit("<stories>[{\"title\":\"only one\"}]</stories>"); err == nil {
+		t.Error("parseSplit accepted a single story")
+	}
+}
+
+func TestReviewStory(t *testing.T) {
+	workDir := fakeAgent(t, "amp", `n=$(cat calls 2>/dev/null || echo 0); echo $((n+1)) > calls
+case $n in
+1) echo '<review>REJECT</review><feedback>Missing test for empty input.</feedback>' ;;
+3) echo 'Looks good. <review>APPROVE</review>' ;;
+*) sed -i 's/"passes": false/"passes": true/' prd.json; echo '<promise>COMPLETE</promise>' ;;
+esac`)
+	if err := savePRDFile(filepath.Join(workDir, "prd.json"), &prd{Project: "demo", BranchName: "ralph/review",
+		UserStories: []userStory{{ID: "US-001", Title: "Parse input", Priority: 1}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config{tool: "amp", maxIterations: 3, review: &reviewConfig{}, workDir: workDir}
+	if got := runLoop(cfg); got != exitComplete {
+		t.Fatalf("runLoop = %d, want %d", got, exitComplete)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "calls")); strings.TrimSpace(string(data)) != "4" {
+		t.Errorf("agent calls = %s, want 2 iterations and 2 reviews", data)
+	}
+	p, _, _ := loadPRD(workDir)
+	if st := p.UserStories[0]; !st.Passes || st.Notes != "Review (iteration 1) rejected: Missing test for empty input." {
+		t.Errorf("story after review = %+v", st)
+	}
+
+	if approved, _, ok := parseReview("no verdict"); ok || approved {
+		t.Error("parseReview accepted a reply without a verdict")
+	}
+}
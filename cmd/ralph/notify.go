@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// notifySink is a notification target from the "notify" list in
+// .ralph/config.json.
+type notifySink struct {
+	Type    string   `json:"type"`              // webhook, slack or command
+	URL     string   `json:"url,omitempty"`     // webhook and slack
+	Command string   `json:"command,omitempty"` // command, e.g. notify-send
+	Events  []string `json:"events,omitempty"`  // story_complete and/or outcomes (complete, max_iterations, ...); empty = all
+}
+
+// notification is the payload sent to sinks. Event is "story_complete" or
+// "finish"; a finish carries the outcome and exit code.
+type notification struct {
+	Event      string    `json:"event"`
+	Outcome    string    `json:"outcome,omitempty"`
+	ExitCode   *int      `json:"exit_code,omitempty"`
+	Message    string    `json:"message"`
+	Project    string    `json:"project"`
+	Branch     string    `json:"branch"`
+	PRD        string    `json:"prd"`
+	Iteration  int       `json:"iteration"`
+	StoryID    string    `json:"story_id,omitempty"`
+	StoryTitle string    `json:"story_title,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// notifyTimeout bounds each notification, so a sink that hangs can't hold
+// up the run.
+var notifyTimeout = 10 * time.Second
+
+var notifyClient = &http.Client{Timeout: notifyTimeout}
+
+func (s notifySink) wants(n notification) bool {
+	return len(s.Events) == 0 || contains(s.Events, n.Event) || (n.Outcome != "" && contains(s.Events, n.Outcome))
+}
+
+// text is the one-line summary used for Slack and desktop notifications.
+func (n notification) text() string {
+	if n.Event == "story_complete" {
+		return fmt.Sprintf("ralph: %s %s passed (%s, iteration %d)", n.StoryID, n.StoryTitle, n.Project, n.Iteration)
+	}
+	return fmt.Sprintf("ralph: %s %s - %s", n.Project, n.Outcome, n.Message)
+}
+
+// notify sends n to every sink that wants it. Failures are logged, never
+// fatal: a broken webhook must not stop or fail a run.
+func notify(sinks []notifySink, n notification) {
+	n.Time = time.Now().UTC()
+	for _, s := range sinks {
+		if !s.wants(n) {
+			continue
+		}
+		if err := s.send(n); err != nil {
+			logWarning("Notification (%s): %v", s.Type, err)
+		}
+	}
+}
+
+func (s notifySink) send(n notification) error {
+	switch s.Type {
+	case "webhook":
+		return postJSON(s.URL, n)
+	case "slack":
+		return postJSON(s.URL, map[string]string{"text": n.text()})
+	case "command":
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		cmd := shellCommand(ctx, s.Command)
+		// Don't wait on children of the shell that still hold its output.
+		cmd.WaitDelay = time.Second
+		cmd.Env = append(os.Environ(),
+			"RALPH_EVENT="+n.Event,
+			"RALPH_OUTCOME="+n.Outcome,
+			"RALPH_MESSAGE="+n.text(),
+			"RALPH_PROJECT="+n.Project,
+			"RALPH_STORY_ID="+n.StoryID,
+			"RALPH_ITERATION="+strconv.Itoa(n.Iteration))
+		if n.ExitCode != nil {
+			cmd.Env = append(cmd.Env, "RALPH_EXIT_CODE="+strconv.Itoa(*n.ExitCode))
+		}
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%q: %w: %s", s.Command, err, bytes.TrimSpace(out))
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown sink type %q: use webhook, slack or command", s.Type)
+	}
+}
+
+func postJSON(url string, v any) error {
+	if url == "" {
+		return fmt.Errorf("no url configured")
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp, err := notifyClient.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Output modes selected with --output.
+const (
+	outputText  = "text"  // colors, banner and spinner (default)
+	outputPlain = "plain" // no colors or animation, same lines as text
+	outputJSON  = "json"  // newline-delimited JSON events on stdout
+)
+
+var (
+	outMode    = outputText
+	outQuiet   = false // only warnings, errors and the final outcome
+	outAnimate = true  // spinner frames; off when stdout is not a terminal
+
+	// agentOutput receives the agent's own output as it runs. It is stderr
+	// so that stdout stays parseable in json mode.
+	agentOutput io.Writer = os.Stderr
+
+	// uiOut and uiErr receive ralph's own output. The TUI redirects them
+	// into its log pane while it owns the screen.
+	uiOut io.Writer = os.Stdout
+	uiErr io.Writer = os.Stderr
+)
+
+// setupOutput applies the output flags and environment. Colors are disabled
+// for plain and json modes, when NO_COLOR is set, and when stdout is not a
+// terminal; the spinner is disabled in all of those cases too, and with
+// --quiet.
+func setupOutput(mode string, quiet bool) {
+	outMode = mode
+	outQuiet = quiet
+	if quiet {
+		agentOutput = io.Discard
+	}
+
+	tty := isTerminal(os.Stdout)
+	if mode != outputText || !tty || os.Getenv("NO_COLOR") != "" {
+		disableColors()
+	}
+	if mode != outputText || !tty || quiet {
+		outAnimate = false
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// emit writes one JSON event line to stdout.
+func emit(event string, fields map[string]any) {
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	fields["event"] = event
+	fields["time"] = time.Now().UTC().Format(time.RFC3339)
+	data, err := json.Marshal(fields)
+	if err != nil {
+		data, _ = json.Marshal(map[string]any{"event": "error", "message": err.Error()})
+	}
+	fmt.Fprintln(uiOut, string(data))
+}
+
+// blankLine prints a spacer line in the human-readable modes.
+func blankLine() {
+	if outMode != outputJSON && !outQuiet {
+		fmt.Fprintln(uiOut)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// patternsFile collects the Codebase Patterns sections of finished runs in
+// .ralph/ of the state directory, so what agents learned about the codebase
+// outlives the progress.txt it was written in.
+const patternsFile = "patterns.md"
+
+const patternsFileHeader = "# Codebase Patterns\n\nCollected by ralph from the progress logs of earlier PRDs.\n\n"
+
+// loadPatterns returns the saved patterns, one bullet per line.
+func loadPatterns(stateDir string) []string {
+	data, err := os.ReadFile(filepath.Join(ralphDir(stateDir), patternsFile))
+	if err != nil {
+		return nil
+	}
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* ") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns
+}
+
+// mergePatterns adds the patterns not already saved and returns how many
+// were new. Patterns are compared ignoring bullet style, case and spacing.
+func mergePatterns(stateDir string, patterns []string) (int, error) {
+	saved := loadPatterns(stateDir)
+	seen := map[string]bool{}
+	for _, p := range saved {
+		seen[patternKey(p)] = true
+	}
+	added := 0
+	for _, p := range patterns {
+		key := patternKey(p)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		saved = append(saved, "- "+strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(p), "-*")))
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+
+	if err := os.MkdirAll(ralphDir(stateDir), 0755); err != nil {
+		return 0, err
+	}
+	content := patternsFileHeader + strings.Join(saved, "\n") + "\n"
+	return added, os.WriteFile(filepath.Join(ralphDir(stateDir), patternsFile), []byte(content), 0644)
+}
+
+func patternKey(p string) string {
+	p = strings.TrimLeft(strings.TrimSpace(p), "-* ")
+	return strings.ToLower(strings.Join(strings.Fields(p), " "))
+}
+
+// harvestPatterns saves the Codebase Patterns from the workspace's
+// progress.txt before the run is archived and its progress reset.
+func harvestPatterns(ws workspace) error {
+	prog, err := loadProgress(ws.dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	added, err := mergePatterns(ws.root, prog.patterns)
+	if err != nil {
+		return err
+	}
+	if added > 0 {
+		logInfo("Saved %d new codebase patterns to %s", added, filepath.Join(ralphDir(ws.root), patternsFile))
+	}
+	return nil
+}
+
+// patternsNote is appended to the prompt so agents start from what earlier
+// PRDs learned. The file is named relative to workDir, where the agent runs.
+func patternsNote(workDir, stateDir string) string {
+	patterns := loadPatterns(stateDir)
+	if len(patterns) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n## Patterns From Earlier PRDs\n\nAgents working on earlier PRDs in this repository recorded these codebase patterns (%s). Follow them unless the code has since changed; add new ones to the Codebase Patterns section of progress.txt as usual:\n%s\n",
+		relPath(workDir, filepath.Join(ralphDir(stateDir), patternsFile)), strings.Join(patterns, "\n"))
+}
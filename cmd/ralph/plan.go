@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// planNonInteractive replaces the PRD skill's clarifying questions: nobody is
+// there to answer them during `ralph plan`.
+const planNonInteractive = `
+## Non-interactive mode
+
+You are running without a user to answer questions. Do NOT ask clarifying
+questions: choose the most reasonable answer to each one yourself and list
+those assumptions under Open Questions in the PRD.
+`
+
+// runPlan turns a feature description into a PRD by running the agent
+// through the prd skill (description to markdown) and the ralph skill
+// (markdown to prd.json), validates the result and shows the stories before
+// offering to start a run.
+func runPlan(cfg *config, in io.Reader) int {
+	description := strings.TrimSpace(strings.Join(cfg.args, " "))
+	if description == "" {
+		logError("Usage: ralph plan \"feature description\"")
+		return exitError
+	}
+	if _, err := exec.LookPath(cfg.tool); err != nil {
+		logError("%s not found on PATH: %v", cfg.tool, err)
+		return exitAgentNotFound
+	}
+	workDir, ws := cfg.workDir, cfg.ws
+	p := &prompter{in: bufio.NewReader(in), yes: cfg.yes}
+
+	if existing, _, _ := loadPRDFile(ws.prd); existing != nil && len(existing.UserStories) > 0 {
+		if !p.confirm(fmt.Sprintf("%s has %d stories; archive it and plan a new one?", relPath(workDir, ws.prd), len(existing.UserStories)), false) {
+			logInfo("Keeping %s", relPath(workDir, ws.prd))
+			return exitError
+		}
+		if err := archiveNow(ws, true); err != nil {
+			logError("Archiving %s: %v", relPath(workDir, ws.prd), err)
+			return exitError
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	markdown := "tasks/prd-" + slugify(description) + ".md"
+	var spent usage
+	run := func(step, prompt string) error {
+		logInfo("%s", step)
+		spin := newSpinner(fmt.Sprintf("%srunning %s%s", colorMuted, cfg.tool, colorReset))
+		spin.Start()
+		_, u, err := runTool(ctx, cfg, prompt)
+		spin.Stop()
+		blankLine()
+		spent.add(u)
+		return err
+	}
+
+	prompt := skillPRD + planNonInteractive + fmt.Sprintf("\n## Feature Description\n\n%s\n\nSave the PRD to %s.\n", description, markdown)
+	if err := run("Writing the PRD for: "+description, prompt); err != nil {
+		return planFailed(ctx, err)
+	}
+	if !fileExists(filepath.Join(workDir, markdown)) {
+		logError("The agent did not write %s", markdown)
+		return exitError
+	}
+	logSuccess("Wrote %s", markdown)
+
+	target := relPath(workDir, ws.prd)
+	prompt = skillRalph + fmt.Sprintf("\n## Task\n\nConvert the PRD in %s and write the result to %s (not prd.json in the current directory, unless that is the same file). Do not change any other file.\n", markdown, target)
+	if err := run("Converting "+markdown+" to "+target, prompt); err != nil {
+		return planFailed(ctx, err)
+	}
+
+	plan, exists, err := loadPRDFile(ws.prd)
+	switch {
+	case err != nil:
+		logError("%v", err)
+		return exitInvalidPRD
+	case !exists:
+		logError("The agent did not write %s", target)
+		return exitInvalidPRD
+	case len(plan.UserStories) == 0:
+		logError("%s has no user stories", target)
+		return exitInvalidPRD
+	}
+	if problems := validatePRD(plan); len(problems) > 0 {
+		logError("%s: %s", target, strings.Join(problems, "; "))
+		return exitInvalidPRD
+	}
+
+	printPlan(plan)
+	if spent.costUSD > 0 {
+		logInfo("Planning cost $%.2f", spent.costUSD)
+	}
+	if !p.confirm("Start the run now?", false) {
+		logSuccess("Review %s and %s, then run 'ralph' to start", markdown, target)
+		return 0
+	}
+	blankLine()
+	return runLoop(cfg)
+}
+
+func planFailed(ctx context.Context, err error) int {
+	if ctx.Err() != nil {
+		return exitInterrupted
+	}
+	logError("%v", err)
+	if errors.Is(err, exec.ErrNotFound) {
+		return exitAgentNotFound
+	}
+	return exitError
+}
+
+// printPlan lists the stories in priority order with their acceptance
+// criteria.
+func printPlan(p *prd) {
+	if outMode == outputJSON {
+		emit("plan", map[string]any{"project": p.Project, "branch": p.BranchName, "stories": p.UserStories})
+		return
+	}
+	fmt.Fprintf(uiOut, "  %s%s%s  %s%s%s  %d stories\n\n", colorBold, p.Project, colorReset, colorOrcGold, p.BranchName, colorReset, len(p.UserStories))
+	stories := append([]userStory(nil), p.UserStories...)
+	sort.SliceStable(stories, func(a, b int) bool { return stories[a].Priority < stories[b].Priority })
+	for _, s := range stories {
+		fmt.Fprintf(uiOut, "  %s%-8s%s %s %s(priority %d)%s\n", colorBold, s.ID, colorReset, s.Title, colorMuted, s.Priority, colorReset)
+		for _, c := range s.AcceptanceCriteria {
+			fmt.Fprintf(uiOut, "           %s- %s%s\n", colorMuted, c, colorReset)
+		}
+	}
+	blankLine()
+}
+
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugify makes a short kebab-case file name from the first words of s.
+func slugify(s string) string {
+	words := strings.Fields(slugPattern.ReplaceAllString(strings.ToLower(s), " "))
+	if len(words) > 5 {
+		words = words[:5]
+	}
+	if len(words) == 0 {
+		return "feature"
+	}
+	return strings.Join(words, "-")
+}
+
+func relPath(base, path string) string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
@@ -27,28 +27,70 @@ type prd struct {
 	UserStories []userStory `json:"userStories"`
 }
 
-func loadPRD(workDir string) (*prd, bool, error) {
-	prdPath := filepath.Join(workDir, "prd.json")
+// passingCount returns the number of stories marked passes: true.
+func (p *prd) passingCount() int {
+	n := 0
+	for _, s := range p.UserStories {
+		if s.Passes {
+			n++
+		}
+	}
+	return n
+}
 
+// validatePRD returns what is wrong with the stories beyond parsing: every
+// story needs an id and a title, and ids must be unique.
+func validatePRD(p *prd) []string {
+	seen := map[string]bool{}
+	var problems []string
+	for i, s := range p.UserStories {
+		switch {
+		case s.ID == "":
+			problems = append(problems, fmt.Sprintf("story %d has no id", i+1))
+		case seen[s.ID]:
+			problems = append(problems, "duplicate id "+s.ID)
+		}
+		seen[s.ID] = true
+		if s.Title == "" {
+			problems = append(problems, fmt.Sprintf("story %d has no title", i+1))
+		}
+	}
+	return problems
+}
+
+func loadPRD(stateDir string) (*prd, bool, error) {
+	return loadPRDFile(filepath.Join(stateDir, "prd.json"))
+}
+
+func loadPRDFile(prdPath string) (*prd, bool, error) {
 	if _, err := os.Stat(prdPath); os.IsNotExist(err) {
 		return nil, false, nil
 	}
 
 	data, err := os.ReadFile(prdPath)
 	if err != nil {
-		return nil, true, fmt.Errorf("reading prd.json: %w", err)
+		return nil, true, fmt.Errorf("reading %s: %w", filepath.Base(prdPath), err)
 	}
 
 	var p prd
 	if err := json.Unmarshal(data, &p); err != nil {
-		return nil, true, fmt.Errorf("parsing prd.json: %w", err)
+		return nil, true, fmt.Errorf("parsing %s: %w", filepath.Base(prdPath), err)
 	}
 
 	return &p, true, nil
 }
 
-func initProgressFile(workDir string) error {
-	progressPath := filepath.Join(workDir, "progress.txt")
+// savePRDFile writes p to prdPath, indented like the PRDs the skills write.
+func savePRDFile(prdPath string, p *prd) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(prdPath, append(data, '\n'), 0644)
+}
+
+func initProgressFile(stateDir string) error {
+	progressPath := filepath.Join(stateDir, "progress.txt")
 	if _, err := os.Stat(progressPath); err == nil {
 		logInfo("progress.txt exists at %s", progressPath)
 		return nil
@@ -59,23 +101,26 @@ func initProgressFile(workDir string) error {
 	return os.WriteFile(progressPath, []byte(content), 0644)
 }
 
-func resetProgressFile(workDir string) error {
-	progressPath := filepath.Join(workDir, "progress.txt")
+func resetProgressFile(stateDir string) error {
+	progressPath := filepath.Join(stateDir, "progress.txt")
 	logInfo("Resetting progress.txt")
+	if err := os.Remove(filepath.Join(stateDir, progressArchiveFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	content := fmt.Sprintf("# Ralph Progress Log\nStarted: %s\n---\n", time.Now().Format(time.RFC1123))
 	return os.WriteFile(progressPath, []byte(content), 0644)
 }
 
-func readLastBranch(workDir string) string {
-	data, err := os.ReadFile(filepath.Join(workDir, ".ralph-branch"))
+func readLastBranch(stateDir string) string {
+	data, err := os.ReadFile(filepath.Join(stateDir, ".ralph-branch"))
 	if err != nil {
 		return ""
 	}
 	return strings.TrimSpace(string(data))
 }
 
-func writeLastBranch(workDir, branch string) error {
-	return os.WriteFile(filepath.Join(workDir, ".ralph-branch"), []byte(branch), 0644)
+func writeLastBranch(stateDir, branch string) error {
+	return os.WriteFile(filepath.Join(stateDir, ".ralph-branch"), []byte(branch), 0644)
 }
 
 func copyFile(src, dst string) error {
@@ -95,8 +140,8 @@ func copyFile(src, dst string) error {
 	return err
 }
 
-func archivePreviousRun(workDir string, p *prd) error {
-	lastBranch := readLastBranch(workDir)
+func archivePreviousRun(ws workspace, p *prd) error {
+	lastBranch := readLastBranch(ws.dir)
 	if lastBranch == "" {
 		logInfo("No previous branch recorded")
 		return nil
@@ -106,55 +151,109 @@ func archivePreviousRun(workDir string, p *prd) error {
 		return nil
 	}
 
-	prdPath := filepath.Join(workDir, "prd.json")
-	progressPath := filepath.Join(workDir, "progress.txt")
-
-	if _, err := os.Stat(prdPath); os.IsNotExist(err) {
+	if _, err := os.Stat(ws.prd); os.IsNotExist(err) {
 		return nil
 	}
 
-	folderName := strings.TrimPrefix(lastBranch, "ralph/")
-	archiveFolder := filepath.Join(workDir, "archive", time.Now().Format("2006-01-02")+"-"+folderName)
-
 	logInfo("Branch changed: %s -> %s", lastBranch, p.BranchName)
+	if _, err := archiveRun(ws, lastBranch); err != nil {
+		return err
+	}
+
+	if err := resetJournal(ws.dir); err != nil {
+		return err
+	}
+	return resetProgressFile(ws.dir)
+}
+
+// archiveRun copies the PRD (as prd.json), progress.txt and the run journal
+// with its transcripts into a dated folder under the workspace's archive/
+// named after branch, writes meta.json describing the run, and returns the
+// folder path. Folder names get a numeric suffix rather than overwriting an
+// earlier archive. The run's Codebase Patterns are merged into
+// .ralph/patterns.md first.
+func archiveRun(ws workspace, branch string) (string, error) {
+	progressPath := filepath.Join(ws.dir, "progress.txt")
+
+	folderName := strings.ReplaceAll(strings.TrimPrefix(branch, "ralph/"), "/", "-")
+	archiveFolder := uniqueDir(filepath.Join(ws.dir, "archive", time.Now().Format("2006-01-02")+"-"+folderName))
+
 	logInfo("Archiving previous run to %s", archiveFolder)
 
 	if err := os.MkdirAll(archiveFolder, 0755); err != nil {
-		return err
+		return "", err
 	}
 
-	if err := copyFile(prdPath, filepath.Join(archiveFolder, "prd.json")); err != nil {
-		return err
+	if err := copyFile(ws.prd, filepath.Join(archiveFolder, "prd.json")); err != nil {
+		return "", err
 	}
 	if _, err := os.Stat(progressPath); err == nil {
 		if err := copyFile(progressPath, filepath.Join(archiveFolder, "progress.txt")); err != nil {
-			return err
+			return "", err
 		}
 	}
+	if older := filepath.Join(ws.dir, progressArchiveFile); fileExists(older) {
+		if err := copyFile(older, filepath.Join(archiveFolder, progressArchiveFile)); err != nil {
+			return "", err
+		}
+	}
+	if err := archiveJournal(ws.dir, archiveFolder); err != nil {
+		return "", err
+	}
+	if err := writeArchiveMeta(ws.dir, archiveFolder, branch); err != nil {
+		return "", err
+	}
+	if err := harvestPatterns(ws); err != nil {
+		logWarning("Saving codebase patterns: %v", err)
+	}
 
 	logSuccess("Archived to: %s", archiveFolder)
+	return archiveFolder, nil
+}
 
-	return resetProgressFile(workDir)
+// uniqueDir returns path, or path with the first free "-N" suffix if it
+// already exists.
+func uniqueDir(path string) string {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return path
+	}
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s-%d", path, n)
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
 }
 
-func checkClaudeMD(workDir string) bool {
-	locations := []string{
-		filepath.Join(workDir, "CLAUDE.md"),
-		filepath.Join(workDir, ".claude", "CLAUDE.md"),
+// copyDir copies the regular files in src (not subdirectories) into dst.
+func copyDir(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
 	}
-	for _, path := range locations {
-		if _, err := os.Stat(path); err == nil {
-			return true
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		if err := copyFile(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+			return err
 		}
 	}
-	return false
+	return nil
 }
 
-func cleanWorkDir(workDir string) error {
-	files := []string{"prd.json", "progress.txt", ".ralph-branch"}
+// cleanWorkspace removes the PRD and the run state kept alongside it.
+func cleanWorkspace(ws workspace) error {
+	files := []string{ws.prd}
+	for _, f := range []string{"progress.txt", progressArchiveFile, ".ralph-branch", pauseFile} {
+		files = append(files, filepath.Join(ws.dir, f))
+	}
 	removed := 0
-	for _, f := range files {
-		path := filepath.Join(workDir, f)
+	for _, path := range files {
+		f := filepath.Base(path)
 		err := os.Remove(path)
 		if err == nil {
 			logInfo("Removed %s", f)
@@ -163,6 +262,13 @@ func cleanWorkDir(workDir string) error {
 			return fmt.Errorf("removing %s: %w", f, err)
 		}
 	}
+	if _, err := os.Stat(filepath.Join(ralphDir(ws.dir), journalFile)); err == nil {
+		if err := resetJournal(ws.dir); err != nil {
+			return fmt.Errorf("removing run journal: %w", err)
+		}
+		logInfo("Removed run journal and transcripts")
+		removed++
+	}
 	if removed > 0 {
 		logSuccess("Cleaned working directory")
 	} else {
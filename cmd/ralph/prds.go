@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// prdsDir is the folder in the state directory holding named PRDs, so
+// several features can be worked on in the same repository.
+const prdsDir = "prds"
+
+// workspace locates one PRD and the folder holding its progress.txt,
+// .ralph-branch, pause file, archive/ and run journal. The default prd.json
+// uses the state directory itself; a named PRD such as prds/auth.json gets
+// a folder next to it named after it (prds/auth/).
+type workspace struct {
+	name string // "" for the default prd.json
+	prd  string
+	dir  string
+	root string // the state directory, shared by all PRDs
+}
+
+func defaultWorkspace(stateDir string) workspace {
+	return workspace{prd: filepath.Join(stateDir, "prd.json"), dir: stateDir, root: stateDir}
+}
+
+func namedWorkspace(stateDir, prdPath string) workspace {
+	dir := strings.TrimSuffix(prdPath, filepath.Ext(prdPath))
+	return workspace{name: filepath.Base(dir), prd: prdPath, dir: dir, root: stateDir}
+}
+
+// resolveWorkspace turns the --prd value into a workspace. A bare name
+// refers to prds/<name>.json in the state directory; anything else is a path
+// relative to the repository root.
+func resolveWorkspace(cfg *config) workspace {
+	if cfg.prd == "" {
+		return defaultWorkspace(cfg.stateDir)
+	}
+	path := cfg.prd
+	if !strings.ContainsAny(path, `/\`) && filepath.Ext(path) == "" {
+		path = filepath.Join(cfg.stateDir, prdsDir, path+".json")
+	} else if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.workDir, path)
+	}
+	return namedWorkspace(cfg.stateDir, path)
+}
+
+// listWorkspaces returns the default prd.json, if present, followed by the
+// named PRDs in prds/ in name order.
+func listWorkspaces(stateDir string) ([]workspace, error) {
+	var list []workspace
+	if def := defaultWorkspace(stateDir); fileExists(def.prd) {
+		list = append(list, def)
+	}
+	files, err := filepath.Glob(filepath.Join(stateDir, prdsDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		list = append(list, namedWorkspace(stateDir, f))
+	}
+	return list, nil
+}
+
+// listPRDs prints every PRD in the state directory with its progress and the
+// outcome of its last run.
+func listPRDs(stateDir string) error {
+	list, err := listWorkspaces(stateDir)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		logInfo("No PRDs in %s (add prd.json or %s/<name>.json)", stateDir, prdsDir)
+		return nil
+	}
+
+	fmt.Fprintf(uiOut, "  %s%-16s %-28s %-8s %s%s\n", colorBold, "NAME", "BRANCH", "PASSING", "STATUS", colorReset)
+	for _, ws := range list {
+		name := ws.name
+		if name == "" {
+			name = "(default)"
+		}
+		branch, passing := "-", "-"
+		if p, _, err := loadPRDFile(ws.prd); err != nil {
+			passing = "invalid"
+		} else if p != nil {
+			branch = p.BranchName
+			passing = fmt.Sprintf("%d/%d", p.passingCount(), len(p.UserStories))
+		}
+		fmt.Fprintf(uiOut, "  %-16s %s%-28s%s %-8s %s\n", name, colorOrcGold, branch, colorReset, passing, workspaceStatus(ws))
+	}
+	return nil
+}
+
+// workspaceStatus describes the last run recorded for ws.
+func workspaceStatus(ws workspace) string {
+	status := "-"
+	if j, err := loadJournal(ws.dir); err == nil && len(j.Runs) > 0 {
+		status = j.current().Outcome
+	}
+	if pauseFileExists(ws.dir) {
+		status += " (pause requested)"
+	}
+	return status
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// progressLog is progress.txt split into the parts the prompt asks the agent
+// to maintain: a Codebase Patterns section at the top and one appended entry
+// per iteration.
+type progressLog struct {
+	header   []string // lines before the first section, e.g. "Started: ..."
+	patterns []string // bullets of ## Codebase Patterns
+	entries  []progressEntry
+}
+
+// progressEntry is one "## [Date/Time] - [Story ID]" section.
+type progressEntry struct {
+	heading   string
+	time      string
+	storyID   string
+	lines     []string // body, without the trailing --- separator
+	learnings []string
+}
+
+const patternsHeading = "## Codebase Patterns"
+
+// storyIDPattern matches ids like US-003, including the US-003a and
+// US-003a1 of split stories.
+var storyIDPattern = regexp.MustCompile(`\b[A-Z][A-Z0-9]*-\d+[a-z0-9]*\b`)
+
+func parseProgress(data string) *progressLog {
+	prog := &progressLog{}
+	var entry *progressEntry
+	inPatterns, inLearnings := false, false
+
+	flush := func() {
+		if entry != nil {
+			entry.lines = trimSeparators(entry.lines)
+			prog.entries = append(prog.entries, *entry)
+			entry = nil
+		}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, patternsHeading):
+			flush()
+			inPatterns = true
+			continue
+		case strings.HasPrefix(line, "## "):
+			flush()
+			inPatterns, inLearnings = false, false
+			entry = newProgressEntry(strings.TrimPrefix(line, "## "))
+			continue
+		case trimmed == "---" || trimmed == "":
+			// Kept inside sections, so code blocks and paragraphs survive
+			// a rewrite; only the trailing separators are dropped.
+			inLearnings = false
+			switch {
+			case inPatterns:
+				prog.patterns = append(prog.patterns, line)
+			case entry != nil:
+				entry.lines = append(entry.lines, line)
+			}
+			continue
+		}
+
+		switch {
+		case inPatterns:
+			prog.patterns = append(prog.patterns, line)
+		case entry != nil:
+			entry.lines = append(entry.lines, line)
+			if strings.Contains(strings.ToLower(line), "learnings") {
+				inLearnings = true
+			} else if inLearnings && line != strings.TrimLeft(line, " \t") {
+				entry.learnings = append(entry.learnings, strings.TrimPrefix(trimmed, "- "))
+			} else {
+				inLearnings = false
+			}
+		default:
+			prog.header = append(prog.header, line)
+		}
+	}
+	flush()
+	prog.patterns = trimSeparators(prog.patterns)
+	return prog
+}
+
+// trimSeparators drops the blank and --- lines that end a section.
+func trimSeparators(lines []string) []string {
+	for len(lines) > 0 {
+		if l := strings.TrimSpace(lines[len(lines)-1]); l != "" && l != "---" {
+			break
+		}
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func newProgressEntry(heading string) *progressEntry {
+	e := &progressEntry{heading: heading, time: heading}
+	if id := storyIDPattern.FindString(heading); id != "" {
+		e.storyID = id
+		if before, _, ok := strings.Cut(heading, " - "+id); ok {
+			e.time = strings.TrimSpace(before)
+		}
+	}
+	return e
+}
+
+func (e progressEntry) text() string {
+	return "## " + e.heading + "\n" + strings.Join(e.lines, "\n")
+}
+
+func loadProgress(dir string) (*progressLog, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "progress.txt"))
+	if err != nil {
+		return nil, err
+	}
+	return parseProgress(string(data)), nil
+}
+
+// progressRewrites returns the headings of entries in before that are missing
+// or changed in after. The agent may edit Codebase Patterns, but entries are
+// append-only.
+func progressRewrites(before, after *progressLog) []string {
+	var changed []string
+	for i, e := range before.entries {
+		if i >= len(after.entries) || after.entries[i].text() != e.text() {
+			changed = append(changed, e.heading)
+		}
+	}
+	return changed
+}
+
+// recentProgress returns the headings of the last n entries in progress.txt.
+func recentProgress(dir string, n int) []string {
+	prog, err := loadProgress(dir)
+	if err != nil {
+		return nil
+	}
+	entries := prog.entries
+	if len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	var headings []string
+	for _, e := range entries {
+		headings = append(headings, e.heading)
+	}
+	return headings
+}
+
+// runProgressCommand handles `ralph progress <show|patterns|tail [n]|compact>`
+// and returns the process exit code.
+func runProgressCommand(cfg *config) int {
+	sub := "show"
+	if len(cfg.args) > 0 {
+		sub = cfg.args[0]
+	}
+
+	prog, err := loadProgress(cfg.ws.dir)
+	if os.IsNotExist(err) {
+		logInfo("No progress.txt in %s yet", cfg.ws.dir)
+		return 0
+	}
+	if err != nil {
+		logError("%v", err)
+		return exitError
+	}
+
+	switch sub {
+	case "show":
+		progressShow(prog)
+	case "patterns":
+		if len(prog.patterns) == 0 {
+			logInfo("No Codebase Patterns recorded yet")
+		}
+		for _, p := range prog.patterns {
+			fmt.Fprintln(uiOut, p)
+		}
+	case "tail":
+		n := 3
+		if len(cfg.args) > 1 {
+			if n, err = strconv.Atoi(cfg.args[1]); err != nil || n < 1 {
+				logError("usage: ralph progress tail [n]")
+				return exitError
+			}
+		}
+		entries := prog.entries
+		if len(entries) > n {
+			entries = entries[len(entries)-n:]
+		}
+		for _, e := range entries {
+			fmt.Fprintf(uiOut, "%s\n\n", e.text())
+		}
+	case "compact":
+		keep := defaultCompactKeep
+		if cfg.keep >= 0 {
+			keep = cfg.keep
+		}
+		moved, err := compactProgress(cfg.ws.dir, keep)
+		if err != nil {
+			logError("Compacting progress.txt: %v", err)
+			return exitError
+		}
+		if moved == 0 {
+			logInfo("Nothing to compact (%d entries, keeping %d)", len(prog.entries), keep)
+		} else {
+			logSuccess("Moved %d entries to %s, kept %d", moved, progressArchiveFile, len(prog.entries)-moved)
+		}
+	default:
+		logError("unknown progress command %q: use show, patterns, tail or compact", sub)
+		return exitError
+	}
+	return 0
+}
+
+func progressShow(prog *progressLog) {
+	patterns := 0
+	for _, l := range prog.patterns {
+		if patternKey(l) != "" {
+			patterns++
+		}
+	}
+	fmt.Fprintf(uiOut, "  %sPATTERNS:%s %d\n", colorBold, colorReset, patterns)
+	fmt.Fprintf(uiOut, "  %sENTRIES:%s  %d\n\n", colorBold, colorReset, len(prog.entries))
+	for _, e := range prog.entries {
+		id := e.storyID
+		if id == "" {
+			id = "-"
+		}
+		fmt.Fprintf(uiOut, "  %-8s %s%s%s\n", id, colorMuted, e.time, colorReset)
+		for _, l := range e.learnings {
+			fmt.Fprintf(uiOut, "    %s•%s %s\n", colorOrcGold, colorReset, l)
+		}
+	}
+}
+
+// progressArchiveFile receives entries moved out of progress.txt by
+// compaction, so the agent reads only recent history.
+const progressArchiveFile = "progress-archive.txt"
+
+// defaultCompactKeep is how many recent entries compaction leaves in
+// progress.txt unless --keep says otherwise.
+const defaultCompactKeep = 5
+
+// String renders the log in the layout the prompt describes.
+func (p *progressLog) String() string {
+	var sb strings.Builder
+	for _, l := range p.header {
+		sb.WriteString(l + "\n")
+	}
+	if len(p.header) > 0 {
+		sb.WriteString("---\n")
+	}
+	if len(p.patterns) > 0 {
+		sb.WriteString(patternsHeading + "\n")
+		for _, l := range p.patterns {
+			sb.WriteString(l + "\n")
+		}
+		sb.WriteString("\n")
+	}
+	for _, e := range p.entries {
+		sb.WriteString(e.text() + "\n---\n")
+	}
+	return sb.String()
+}
+
+// compactProgress moves all but the newest keep entries from progress.txt to
+// progress-archive.txt, leaving the header and Codebase Patterns in place. It
+// returns the number of entries moved.
+func compactProgress(dir string, keep int) (int, error) {
+	prog, err := loadProgress(dir)
+	if err != nil {
+		return 0, err
+	}
+	moved := len(prog.entries) - keep
+	if moved <= 0 {
+		return 0, nil
+	}
+
+	archivePath := filepath.Join(dir, progressArchiveFile)
+	var sb strings.Builder
+	if _, err := os.Stat(archivePath); os.IsNotExist(err) {
+		sb.WriteString("# Ralph Progress Archive\nEntries compacted out of progress.txt, oldest first.\n---\n")
+	}
+	for _, e := range prog.entries[:moved] {
+		sb.WriteString(e.text() + "\n---\n")
+	}
+	f, err := os.OpenFile(archivePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.WriteString(sb.String()); err != nil {
+		f.Close()
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+
+	total := moved
+	var header []string
+	for _, l := range prog.header {
+		if n, ok := strings.CutPrefix(l, "Compacted: "); ok {
+			var prev int
+			fmt.Sscanf(n, "%d", &prev)
+			total += prev
+			continue
+		}
+		header = append(header, l)
+	}
+	prog.header = append(header, fmt.Sprintf("Compacted: %d older entries moved to %s", total, progressArchiveFile))
+	prog.entries = prog.entries[moved:]
+
+	if err := os.WriteFile(filepath.Join(dir, "progress.txt"), []byte(prog.String()), 0644); err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
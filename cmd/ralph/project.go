@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// projectConfigFile holds per-repository settings. It always lives at the
+// repository root so it can be found before the state directory is known.
+const projectConfigFile = ".ralph/config.json"
+
+// projectConfig is the per-repository configuration. Command-line flags
+// override it.
+type projectConfig struct {
+	StateDir     string        `json:"stateDir,omitempty"`     // where prd.json, progress.txt etc. live, relative to the repo root
+	CompactAfter int           `json:"compactAfter,omitempty"` // see --compact-after
+	SplitAfter   int           `json:"splitAfter,omitempty"`   // see --split-after
+	Gates        []string      `json:"gates,omitempty"`        // quality gates, used when no --gate is given
+	Review       *reviewConfig `json:"review,omitempty"`       // reviewer agent settings, see review.go
+	Hooks        *projectHooks `json:"hooks,omitempty"`        // lifecycle hook commands, see hooks.go
+	Notify       []notifySink  `json:"notify,omitempty"`       // notification sinks, see notify.go
+}
+
+// loadProjectConfig reads .ralph/config.json; a missing file yields an
+// empty config.
+func loadProjectConfig(workDir string) (*projectConfig, error) {
+	data, err := os.ReadFile(filepath.Join(workDir, projectConfigFile))
+	if os.IsNotExist(err) {
+		return &projectConfig{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", projectConfigFile, err)
+	}
+	var pc projectConfig
+	if err := json.Unmarshal(data, &pc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", projectConfigFile, err)
+	}
+	return &pc, nil
+}
+
+func saveProjectConfig(workDir string, pc *projectConfig) error {
+	path := filepath.Join(workDir, projectConfigFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(pc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// applyProjectConfig fills in settings not given on the command line,
+// resolves the state directory to an absolute path and picks the PRD
+// workspace.
+func applyProjectConfig(cfg *config, pc *projectConfig) {
+	if cfg.stateDir == "" {
+		cfg.stateDir = pc.StateDir
+	}
+	if cfg.compactAfter == 0 {
+		cfg.compactAfter = pc.CompactAfter
+	}
+	if cfg.splitAfter == 0 {
+		cfg.splitAfter = pc.SplitAfter
+	}
+	if len(cfg.gates) == 0 {
+		cfg.gates = pc.Gates
+	}
+	if cfg.review == nil {
+		cfg.review = pc.Review
+	}
+	if pc.Hooks != nil {
+		cfg.hooks = *pc.Hooks
+	}
+	cfg.notify = pc.Notify
+	switch {
+	case cfg.stateDir == "":
+		cfg.stateDir = cfg.workDir
+	case !filepath.IsAbs(cfg.stateDir):
+		cfg.stateDir = filepath.Join(cfg.workDir, cfg.stateDir)
+	}
+	cfg.ws = resolveWorkspace(cfg)
+}
+
+// stateFiles are the files and folders kept in the state directory.
+var stateFiles = []string{"prd.json", "progress.txt", progressArchiveFile, ".ralph-branch", pauseFile, "archive", prdsDir}
+
+// migrateState moves existing state from the repository root into the
+// configured state directory and records the setting in the project config.
+func migrateState(cfg *config) error {
+	if cfg.stateDir == cfg.workDir {
+		return fmt.Errorf("state directory is the repository root; pass --state-dir <dir> to choose where to move files")
+	}
+	if err := os.MkdirAll(cfg.stateDir, 0755); err != nil {
+		return err
+	}
+
+	type move struct{ from, to string }
+	var moves []move
+	for _, f := range stateFiles {
+		moves = append(moves, move{filepath.Join(cfg.workDir, f), filepath.Join(cfg.stateDir, f)})
+	}
+	for _, f := range []string{journalFile, transcriptsDir, patternsFile} {
+		moves = append(moves, move{filepath.Join(ralphDir(cfg.workDir), f), filepath.Join(ralphDir(cfg.stateDir), f)})
+	}
+
+	// Check everything first so a conflict doesn't leave a half-moved state.
+	for _, m := range moves {
+		if _, err := os.Stat(m.from); err != nil {
+			continue
+		}
+		if _, err := os.Stat(m.to); err == nil {
+			return fmt.Errorf("%s already exists; move or remove it first", m.to)
+		}
+	}
+
+	moved := 0
+	for _, m := range moves {
+		if _, err := os.Stat(m.from); err != nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(m.to), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(m.from, m.to); err != nil {
+			return fmt.Errorf("moving %s: %w", m.from, err)
+		}
+		rel, _ := filepath.Rel(cfg.workDir, m.to)
+		logInfo("Moved %s", rel)
+		moved++
+	}
+
+	pc, err := loadProjectConfig(cfg.workDir)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(cfg.workDir, cfg.stateDir)
+	if err != nil {
+		rel = cfg.stateDir
+	}
+	pc.StateDir = filepath.ToSlash(rel)
+	if err := saveProjectConfig(cfg.workDir, pc); err != nil {
+		return err
+	}
+
+	logSuccess("Moved %d items to %s and saved stateDir in %s", moved, rel, projectConfigFile)
+	return nil
+}
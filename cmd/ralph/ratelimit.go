@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The Claude CLI reports a reached usage limit either with the reset time as
+// a Unix timestamp ("Claude AI usage limit reached|1760000000") or as a clock
+// time ("5-hour limit reached ∙ resets 3pm", "Your limit will reset at 3:30pm
+// (Europe/Berlin)").
+var (
+	usageLimitPattern = regexp.MustCompile(`(?i)usage limit reached|\d+-hour limit reached\s*∙\s*resets|limit will reset at`)
+	resetEpochPattern = regexp.MustCompile(`(?i)limit reached\|(\d{9,})`)
+	resetClockPattern = regexp.MustCompile(`(?i)resets?(?: at)?\s+(\d{1,2})(?::(\d{2}))?\s*(am|pm)(?:\s*\(([^)]+)\))?`)
+)
+
+// usageLimitReset reports whether the end of output says the usage limit was
+// reached, and when it resets. A zero time means no reset time was given.
+func usageLimitReset(output string, now time.Time) (time.Time, bool) {
+	if len(output) > 2000 {
+		output = output[len(output)-2000:]
+	}
+	if !usageLimitPattern.MatchString(output) {
+		return time.Time{}, false
+	}
+	if m := resetEpochPattern.FindStringSubmatch(output); m != nil {
+		if sec, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+			return time.Unix(sec, 0), true
+		}
+	}
+	if m := resetClockPattern.FindStringSubmatch(output); m != nil {
+		hour, _ := strconv.Atoi(m[1])
+		minute, _ := strconv.Atoi(m[2])
+		if hour < 1 || hour > 12 || minute > 59 {
+			return time.Time{}, true
+		}
+		hour %= 12
+		if strings.EqualFold(m[3], "pm") {
+			hour += 12
+		}
+		loc := now.Location()
+		if m[4] != "" {
+			if l, err := time.LoadLocation(m[4]); err == nil {
+				loc = l
+			}
+		}
+		local := now.In(loc)
+		reset := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, loc)
+		if !reset.After(now) {
+			reset = reset.AddDate(0, 0, 1)
+		}
+		return reset, true
+	}
+	return time.Time{}, true
+}
+
+// usageLimitFallback is how long to wait when the CLI says the limit was
+// reached without saying when it resets, or gives a reset time that has
+// already passed.
+var usageLimitFallback = 30 * time.Minute
+
+// maxUsageLimitWaits caps how often one iteration waits out a usage limit
+// before its run counts as a failed one.
+const maxUsageLimitWaits = 3
+
+// waitForReset sleeps until the usage limit resets, showing a countdown, and
+// reports false if ctx ended first. A minute of slack is added so the first
+// request after the reset doesn't race it.
+func waitForReset(ctx context.Context, state *runState, until time.Time) bool {
+	if until.After(time.Now()) {
+		until = until.Add(time.Minute)
+	} else {
+		until = time.Now().Add(usageLimitFallback)
+	}
+	state.setWaitUntil(until)
+	defer state.setWaitUntil(time.Time{})
+
+	logWarning("Usage limit reached; waiting until %s (%s) before resuming", until.Format("Jan 2 15:04 MST"), max(time.Until(until), 0).Round(time.Second))
+	if !outAnimate {
+		return sleepCtx(ctx, time.Until(until))
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	defer fmt.Fprint(uiOut, "\r\033[K")
+	for {
+		left := time.Until(until)
+		if left <= 0 {
+			return true
+		}
+		fmt.Fprintf(uiOut, "\r\033[K  %s⏳ usage limit resets in %s%s", colorMuted, left.Round(time.Second), colorReset)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"regexp"
+	"time"
+)
+
+// failureKind classifies how an agent run ended.
+type failureKind int
+
+const (
+	failureNone      failureKind = iota // the agent exited cleanly
+	failureAgent                        // non-zero exit; the iteration counts and the loop moves on
+	failureTransient                    // rate limited, overloaded or timed out; retried
+	failureFatal                        // retrying cannot help: missing binary, not logged in
+)
+
+var (
+	transientPattern = regexp.MustCompile(`(?i)rate.?limit|overloaded|too many requests|\b(429|502|503|529)\b|temporarily unavailable|connection reset|ECONNRESET|ETIMEDOUT`)
+	fatalPattern     = regexp.MustCompile(`(?i)invalid api key|please run /login|not logged in|authentication.?(error|failed)`)
+)
+
+// retryBackoff is the delay before the first retry; it doubles for each
+// further attempt up to maxRetryBackoff.
+var (
+	retryBackoff    = 10 * time.Second
+	maxRetryBackoff = 5 * time.Minute
+)
+
+// classifyFailure decides what an agent run's error means. timedOut is set
+// when the per-iteration timeout, not the run, ended the agent. Only the end
+// of the output is searched, where the CLI reports why it exited, so an agent
+// that merely writes about rate limits is not mistaken for a rate-limited one.
+func classifyFailure(output string, err error, timedOut bool) (failureKind, string) {
+	if len(output) > 2000 {
+		output = output[len(output)-2000:]
+	}
+	switch {
+	case err == nil:
+		return failureNone, ""
+	case errors.Is(err, exec.ErrNotFound):
+		return failureFatal, "agent binary not found"
+	case timedOut:
+		return failureTransient, "iteration timed out"
+	case fatalPattern.MatchString(output):
+		return failureFatal, "agent is not authenticated: " + fatalPattern.FindString(output)
+	case transientPattern.MatchString(output):
+		return failureTransient, "transient API error: " + transientPattern.FindString(output)
+	}
+	return failureAgent, err.Error()
+}
+
+// backoff returns the delay before retry number attempt (0-based).
+func backoff(attempt int) time.Duration {
+	d := retryBackoff
+	for i := 0; i < attempt && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	if d > maxRetryBackoff {
+		d = maxRetryBackoff
+	}
+	return d
+}
+
+// sleepCtx waits for d and reports false if ctx ended first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// reviewPrompt asks a second agent to check a story the first one marked
+// passing. The reviewer only reads; ralph records its verdict.
+const reviewPrompt = `# Review a Story
+
+You are reviewing work by another agent, who has just marked this story as
+passing:
+
+%s
+
+Changes made while working on it:
+
+%s
+
+Check the changes against the description and every acceptance criterion. Read
+the surrounding code and run the project's checks if you need to. Reject only
+for real problems: an unmet criterion, a bug, or missing or broken tests. Do not
+reject for style preferences.
+
+Do NOT change any files and do NOT commit. End your reply with your verdict:
+
+<review>APPROVE</review>
+
+or
+
+<review>REJECT</review>
+<feedback>What is wrong and what needs to change, specific enough for the next
+iteration to fix it.</feedback>
+`
+
+// maxReviewDiff caps the diff embedded in the review prompt; the reviewer
+// is told how to see the rest.
+const maxReviewDiff = 60000
+
+var (
+	reviewVerdictPattern  = regexp.MustCompile(`(?i)<review>\s*(APPROVE|REJECT)\s*</review>`)
+	reviewFeedbackPattern = regexp.MustCompile(`(?s)<feedback>(.*?)</feedback>`)
+)
+
+// reviewConfig is the "review" setting in .ralph/config.json and the
+// --review flags.
+type reviewConfig struct {
+	Tool  string `json:"tool,omitempty"`  // amp or claude; default: the run's --tool
+	Model string `json:"model,omitempty"` // passed to claude as --model
+}
+
+// reviewStory runs the reviewer on st with the changes since the commit
+// sinceSHA. It reports whether the story was approved, the feedback for a
+// rejection, and what the reviewer cost. A reviewer that fails or gives no
+// verdict returns an error; the story then keeps passing.
+func reviewStory(ctx context.Context, cfg *config, st userStory, sinceSHA string) (bool, string, usage, error) {
+	diff, err := gitDiff(cfg.workDir, sinceSHA, false)
+	if err != nil {
+		diff = fmt.Sprintf("(could not diff: %v; inspect the repository yourself)", err)
+	} else if len(diff) > maxReviewDiff {
+		diff = diff[:maxReviewDiff] + fmt.Sprintf("\n... (truncated; run `git diff %s` for the rest)", sinceSHA)
+	}
+	story, _ := json.MarshalIndent(st, "", "  ")
+	prompt := fmt.Sprintf(reviewPrompt, "```json\n"+string(story)+"\n```", "```diff\n"+diff+"\n```")
+
+	rc := *cfg
+	if cfg.review.Tool != "" {
+		rc.tool = cfg.review.Tool
+	}
+	rc.model = cfg.review.Model
+
+	spin := newSpinner(fmt.Sprintf("%sreviewing %s with %s%s", colorMuted, st.ID, rc.tool, colorReset))
+	spin.Start()
+	output, u, err := runTool(ctx, &rc, prompt)
+	spin.Stop()
+	blankLine()
+	if err != nil {
+		return true, "", u, err
+	}
+	approved, feedback, ok := parseReview(output)
+	if !ok {
+		return true, "", u, fmt.Errorf("no <review> verdict in the reviewer's reply")
+	}
+	return approved, feedback, u, nil
+}
+
+// parseReview reads the last verdict in output and, for a rejection, the
+// feedback.
+func parseReview(output string) (approved bool, feedback string, ok bool) {
+	verdicts := reviewVerdictPattern.FindAllStringSubmatch(output, -1)
+	if len(verdicts) == 0 {
+		return false, "", false
+	}
+	if strings.EqualFold(verdicts[len(verdicts)-1][1], "APPROVE") {
+		return true, "", true
+	}
+	if m := reviewFeedbackPattern.FindAllStringSubmatch(output, -1); len(m) > 0 {
+		feedback = strings.TrimSpace(m[len(m)-1][1])
+	}
+	if feedback == "" {
+		feedback = "rejected without feedback"
+	}
+	return false, feedback, true
+}
+
+// rejectStory marks the story id as not passing again and adds the review
+// feedback to its notes for the next iteration.
+func rejectStory(prdPath, id string, iteration int, feedback string) error {
+	p, _, err := loadPRDFile(prdPath)
+	if err != nil {
+		return err
+	}
+	st := findStory(p, id)
+	if st == nil {
+		return fmt.Errorf("story %s is no longer in the PRD", id)
+	}
+	st.Passes = false
+	note := fmt.Sprintf("Review (iteration %d) rejected: %s", iteration, feedback)
+	if st.Notes != "" {
+		note = st.Notes + "\n" + note
+	}
+	st.Notes = note
+	return savePRDFile(prdPath, p)
+}
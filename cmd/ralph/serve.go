@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Default addresses for `ralph serve` and the control API it talks to.
+const (
+	defaultServeAddr = "127.0.0.1:7780"
+	defaultAPIAddr   = "127.0.0.1:7777"
+)
+
+// `ralph serve` hosts the dashboard (dashboard.go). Live data comes from the
+// control API of a run started with --listen, proxied under /api/; the
+// journal, archives and transcripts are read from disk under /data/, so
+// they can be browsed with no run in progress.
+
+type archiveSummary struct {
+	Name       string    `json:"name"`
+	Date       time.Time `json:"date"`
+	Project    string    `json:"project"`
+	Branch     string    `json:"branch"`
+	Passing    int       `json:"passing"`
+	Total      int       `json:"total"`
+	Iterations int       `json:"iterations"`
+	CostUSD    float64   `json:"cost_usd"`
+}
+
+func newServeHandler(ws workspace, apiAddr string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(dashboardHTML))
+	})
+
+	target := &url.URL{Scheme: "http", Host: apiAddr}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.FlushInterval = -1 // stream /api/output as it arrives
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		writeJSON(w, http.StatusBadGateway, map[string]any{"error": "no run listening on " + apiAddr + " (start one with ralph run --listen " + apiAddr + ")"})
+	}
+	mux.Handle("/api/", proxy)
+
+	mux.HandleFunc("GET /data/journal", func(w http.ResponseWriter, r *http.Request) {
+		j, err := loadJournal(ws.dir)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, j)
+	})
+	mux.HandleFunc("GET /data/transcripts/{file}", func(w http.ResponseWriter, r *http.Request) {
+		serveTranscript(w, filepath.Join(ralphDir(ws.dir), transcriptsDir), r.PathValue("file"))
+	})
+
+	mux.HandleFunc("GET /data/archives", func(w http.ResponseWriter, r *http.Request) {
+		entries, err := listArchives(ws.dir)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		list := []archiveSummary{}
+		for _, e := range entries {
+			s := archiveSummary{Name: e.name, Date: e.date}
+			if e.prd != nil {
+				s.Project, s.Branch = e.prd.Project, e.prd.BranchName
+				s.Passing, s.Total = e.prd.passingCount(), len(e.prd.UserStories)
+			}
+			if e.meta != nil {
+				s.Iterations, s.CostUSD = e.meta.Iterations, e.meta.CostUSD
+			}
+			list = append(list, s)
+		}
+		writeJSON(w, http.StatusOK, list)
+	})
+	mux.HandleFunc("GET /data/archives/{name}", func(w http.ResponseWriter, r *http.Request) {
+		e, err := findArchive(ws.dir, r.PathValue("name"))
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"name": e.name, "prd": e.prd, "meta": e.meta})
+	})
+	mux.HandleFunc("GET /data/archives/{name}/transcripts/{file}", func(w http.ResponseWriter, r *http.Request) {
+		e, err := findArchive(ws.dir, r.PathValue("name"))
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+			return
+		}
+		serveTranscript(w, filepath.Join(e.path, transcriptsDir), r.PathValue("file"))
+	})
+
+	return mux
+}
+
+func serveTranscript(w http.ResponseWriter, dir, file string) {
+	if file != filepath.Base(file) || file == ".." {
+		http.Error(w, "invalid transcript name", http.StatusBadRequest)
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		http.Error(w, "transcript not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(stripANSI(string(data))))
+}
+
+// runServe serves the dashboard until interrupted and returns the process
+// exit code.
+func runServe(cfg *config) int {
+	addr, apiAddr := cfg.listen, cfg.api
+	if addr == "" {
+		addr = defaultServeAddr
+	}
+	if apiAddr == "" {
+		apiAddr = defaultAPIAddr
+	}
+
+	srv := &http.Server{Addr: addr, Handler: loopbackHost(addr, newServeHandler(cfg.ws, apiAddr))}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	logInfo("Dashboard on http://%s (live data from the run listening on %s)", addr, apiAddr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logError("%v", err)
+		return exitError
+	}
+	return 0
+}
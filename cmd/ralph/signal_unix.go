@@ -0,0 +1,31 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchPauseSignal toggles pause on SIGUSR1 until ctx is done.
+func watchPauseSignal(ctx context.Context, state *runState) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ch:
+				if state.togglePause() {
+					logInfo("SIGUSR1: will pause after the current iteration")
+				} else {
+					logInfo("SIGUSR1: resuming")
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package main
+
+import "context"
+
+// watchPauseSignal is a no-op on Windows, which has no SIGUSR1; use
+// `ralph pause` / `ralph resume` instead.
+func watchPauseSignal(ctx context.Context, state *runState) {}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// splitPrompt asks the agent to break up a story that keeps failing. The
+// agent only answers; ralph rewrites prd.json itself so a bad answer can't
+// damage the other stories.
+const splitPrompt = `# Split a Story
+
+The story below has been attempted for %d iterations in a row without passing.
+It is probably too big to finish in one context window.
+
+%s
+
+Read %s for what the previous attempts did and where they got stuck, and look
+at the code as needed. Then split the story into 2-4 smaller stories that
+together cover all of its acceptance criteria. Each must be completable in one
+iteration, and each must be independently verifiable. Order them by
+dependency, and keep "Typecheck passes" (and "Verify in browser" for UI work)
+where the original had it.
+
+Do NOT change any files and do NOT commit. Reply with the new stories as a JSON
+array inside <stories></stories> tags, with only title, description,
+acceptanceCriteria and notes for each:
+
+<stories>
+[
+  {"title": "...", "description": "As a ..., I want ...", "acceptanceCriteria": ["...", "Typecheck passes"], "notes": ""}
+]
+</stories>
+`
+
+// splitStory has the agent split st into smaller stories and replaces it
+// with them in the PRD, recording the split in progress.txt. It returns the
+// new stories and what the agent run cost.
+func splitStory(ctx context.Context, cfg *config, st userStory, fails int) ([]userStory, usage, error) {
+	ws := cfg.ws
+	story, _ := json.MarshalIndent(st, "", "  ")
+	progress := relPath(cfg.workDir, filepath.Join(ws.dir, "progress.txt"))
+	prompt := fmt.Sprintf(splitPrompt, fails, "```json\n"+string(story)+"\n```", progress)
+
+	spin := newSpinner(fmt.Sprintf("%ssplitting %s%s", colorMuted, st.ID, colorReset))
+	spin.Start()
+	output, u, err := runTool(ctx, cfg, prompt)
+	spin.Stop()
+	blankLine()
+	if err != nil {
+		return nil, u, err
+	}
+
+	parts, err := parseSplit(output)
+	if err != nil {
+		return nil, u, err
+	}
+	parts, err = replaceStory(ws.prd, st.ID, parts)
+	if err != nil {
+		return nil, u, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s - %s (split)\n", time.Now().Format("2006-01-02 15:04"), st.ID)
+	fmt.Fprintf(&b, "- Failed %d iterations in a row; ralph replaced it with smaller stories:\n", fails)
+	for _, s := range parts {
+		fmt.Fprintf(&b, "  - %s: %s\n", s.ID, s.Title)
+	}
+	b.WriteString("---\n")
+	if err := appendFile(filepath.Join(ws.dir, "progress.txt"), b.String()); err != nil {
+		logWarning("Recording the split in progress.txt: %v", err)
+	}
+	return parts, u, nil
+}
+
+// parseSplit reads the stories from the last <stories> block in output.
+func parseSplit(output string) ([]userStory, error) {
+	start := strings.LastIndex(output, "<stories>")
+	end := strings.LastIndex(output, "</stories>")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no <stories> block in the agent's reply")
+	}
+	var parts []userStory
+	if err := json.Unmarshal([]byte(output[start+len("<stories>"):end]), &parts); err != nil {
+		return nil, fmt.Errorf("parsing the split stories: %w", err)
+	}
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("the agent proposed %d stories; a split needs at least 2", len(parts))
+	}
+	for i, s := range parts {
+		if strings.TrimSpace(s.Title) == "" {
+			return nil, fmt.Errorf("split story %d has no title", i+1)
+		}
+	}
+	return parts, nil
+}
+
+// replaceStory swaps the story id in the PRD at path for parts, in place so
+// they keep its position and priority. The parts get ids derived from id
+// (US-003a, US-003b, ...) and start out not passing.
+func replaceStory(path, id string, parts []userStory) ([]userStory, error) {
+	p, _, err := loadPRDFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, fmt.Errorf("%s not found", filepath.Base(path))
+	}
+	at := -1
+	for i, s := range p.UserStories {
+		if s.ID == id {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return nil, fmt.Errorf("story %s is no longer in %s", id, filepath.Base(path))
+	}
+
+	orig := p.UserStories[at]
+	for i := range parts {
+		parts[i].ID = subStoryID(id, i)
+		parts[i].Priority = orig.Priority
+		parts[i].Passes = false
+		note := "Split from " + id
+		if parts[i].Notes != "" {
+			note += ". " + parts[i].Notes
+		}
+		parts[i].Notes = note
+	}
+	stories := append([]userStory(nil), p.UserStories[:at]...)
+	stories = append(stories, parts...)
+	p.UserStories = append(stories, p.UserStories[at+1:]...)
+	return parts, savePRDFile(path, p)
+}
+
+// subStoryID names the i-th part of a split story: US-003 becomes US-003a,
+// US-003b, ...; a part split again gets digits, US-003a1, US-003a2, ...
+func subStoryID(id string, i int) string {
+	if n := len(id); n > 0 && id[n-1] >= 'a' && id[n-1] <= 'z' {
+		return fmt.Sprintf("%s%d", id, i+1)
+	}
+	return id + string(rune('a'+i))
+}
+
+func appendFile(path, text string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runState is the live state of a run, shared between the main loop and the
+// interactive front ends (TUI, control API) that observe and steer it.
+type runState struct {
+	mu            sync.Mutex
+	ws            workspace
+	tool          string
+	iteration     int
+	maxIterations int
+	startedAt     time.Time
+	phase         string    // "starting", "running", "waiting", "paused" or "finished"
+	waitUntil     time.Time // when a usage-limit wait ends; zero when not waiting
+	spent         usage
+	budget        budget
+	pause         bool
+	skipped       []string
+	cancel        context.CancelFunc
+	output        *lineBuffer // agent output
+	log           *lineBuffer // ralph's own status lines
+}
+
+func newRunState(cfg *config) *runState {
+	ws := cfg.ws
+	if ws.dir == "" {
+		ws = defaultWorkspace(cfg.workDir)
+	}
+	return &runState{
+		ws:            ws,
+		tool:          cfg.tool,
+		maxIterations: cfg.maxIterations,
+		startedAt:     time.Now(),
+		phase:         "starting",
+		budget:        cfg.budget(),
+		output:        newLineBuffer(500),
+		log:           newLineBuffer(100),
+	}
+}
+
+func (s *runState) setPhase(phase string, iteration int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.phase = phase
+	s.iteration = iteration
+}
+
+func (s *runState) setWaitUntil(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.waitUntil = t
+}
+
+func (s *runState) addUsage(u usage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spent.add(u)
+}
+
+// togglePause asks the loop to pause after the current iteration, or lets a
+// paused loop continue. Resuming also clears a pause requested through the
+// control file. It returns the new pause setting.
+func (s *runState) togglePause() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if pauseFileExists(s.ws.dir) {
+		removePauseFile(s.ws.dir)
+		s.pause = false
+		return false
+	}
+	s.pause = !s.pause
+	return s.pause
+}
+
+// setPause asks the loop to pause after the current iteration, or lets it
+// continue, clearing the control file as well.
+func (s *runState) setPause(pause bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !pause {
+		removePauseFile(s.ws.dir)
+	}
+	s.pause = pause
+}
+
+// pauseRequested reports whether the loop should pause, either through
+// togglePause or the control file.
+func (s *runState) pauseRequested() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pause || pauseFileExists(s.ws.dir)
+}
+
+// abort stops the run, killing the agent if one is running.
+func (s *runState) abort() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// skip marks a story to be left alone for the rest of the run. An empty id
+// skips the story the agent would pick next.
+func (s *runState) skip(id string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == "" {
+		p, _, err := loadPRDFile(s.ws.prd)
+		if err != nil || p == nil {
+			return ""
+		}
+		next := nextStory(p, s.skipped)
+		if next == nil {
+			return ""
+		}
+		id = next.ID
+	}
+	for _, existing := range s.skipped {
+		if existing == id {
+			return id
+		}
+	}
+	s.skipped = append(s.skipped, id)
+	return id
+}
+
+func (s *runState) skippedStories() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.skipped...)
+}
+
+// nextStory returns the highest-priority story that has not passed and is not
+// skipped, mirroring how the prompt tells the agent to choose.
+func nextStory(p *prd, skipped []string) *userStory {
+	var candidates []*userStory
+	for i := range p.UserStories {
+		st := &p.UserStories[i]
+		if st.Passes || contains(skipped, st.ID) {
+			continue
+		}
+		candidates = append(candidates, st)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.SliceStable(candidates, func(a, b int) bool { return candidates[a].Priority < candidates[b].Priority })
+	return candidates[0]
+}
+
+// findStory returns the story with the given id, or nil.
+func findStory(p *prd, id string) *userStory {
+	if p == nil {
+		return nil
+	}
+	for i := range p.UserStories {
+		if p.UserStories[i].ID == id {
+			return &p.UserStories[i]
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// skipNote is appended to the prompt so the agent leaves skipped stories
+// alone.
+func skipNote(skipped []string) string {
+	if len(skipped) == 0 {
+		return ""
+	}
+	return "\n## Skipped Stories\n\nThe operator has asked to skip these stories for this run. Do NOT work on them; treat them as done when checking the stop condition:\n- " +
+		strings.Join(skipped, "\n- ") + "\n"
+}
+
+// lineBuffer is an io.Writer that keeps the last max complete lines written
+// to it, plus any trailing partial line. Subscribers get each complete line
+// as it arrives.
+type lineBuffer struct {
+	mu      sync.Mutex
+	max     int
+	lines   []string
+	partial []byte
+	subs    map[chan string]struct{}
+}
+
+func newLineBuffer(max int) *lineBuffer {
+	return &lineBuffer{max: max}
+}
+
+func (b *lineBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data := append(b.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimRight(string(data[:i]), "\r")
+		b.lines = append(b.lines, line)
+		for ch := range b.subs {
+			select {
+			case ch <- line:
+			default: // a slow reader misses lines rather than blocking the agent
+			}
+		}
+		data = data[i+1:]
+	}
+	b.partial = append([]byte(nil), data...)
+	if len(b.lines) > b.max {
+		b.lines = append([]string(nil), b.lines[len(b.lines)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+// subscribe returns a channel receiving new complete lines and a function
+// that ends the subscription.
+func (b *lineBuffer) subscribe() (<-chan string, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs == nil {
+		b.subs = map[chan string]struct{}{}
+	}
+	ch := make(chan string, 256)
+	b.subs[ch] = struct{}{}
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, ch)
+	}
+}
+
+// tail returns up to n of the most recent lines.
+func (b *lineBuffer) tail(n int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	lines := b.lines
+	if len(b.partial) > 0 {
+		lines = append(append([]string(nil), lines...), string(b.partial))
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return append([]string(nil), lines...)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pauseFile is the control file that pauses a running loop after the
+// current iteration. `ralph pause` creates it and `ralph resume` removes it.
+const pauseFile = ".ralph-pause"
+
+func pauseFileExists(stateDir string) bool {
+	_, err := os.Stat(filepath.Join(stateDir, pauseFile))
+	return err == nil
+}
+
+func writePauseFile(stateDir string) error {
+	return os.WriteFile(filepath.Join(stateDir, pauseFile), nil, 0644)
+}
+
+func removePauseFile(stateDir string) error {
+	err := os.Remove(filepath.Join(stateDir, pauseFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// confirmStep asks before running iteration i in --step mode, showing what
+// the previous iteration changed since sinceSHA. It returns false when the
+// user chooses to stop.
+func confirmStep(in *bufio.Reader, workDir string, i int, sinceSHA string) bool {
+	if i > 1 {
+		stat, err := gitDiff(workDir, sinceSHA, true)
+		switch {
+		case err != nil:
+			logWarning("Could not diff last iteration: %v", err)
+		case stat == "":
+			logInfo("Iteration %d made no changes", i-1)
+		default:
+			fmt.Fprintf(uiOut, "\n  %sChanges from iteration %d:%s\n", colorBold, i-1, colorReset)
+			for _, line := range strings.Split(stat, "\n") {
+				fmt.Fprintf(uiOut, "    %s\n", line)
+			}
+		}
+	}
+
+	for {
+		fmt.Fprintf(uiOut, "\n  %sRun iteration %d?%s [Enter] continue  [d] full diff  [q] quit: ", colorOrcGold, i, colorReset)
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			return false
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "", "y", "yes", "c":
+			return true
+		case "q", "n", "no":
+			return false
+		case "d":
+			diff, err := gitDiff(workDir, sinceSHA, false)
+			if err != nil {
+				logWarning("Could not diff last iteration: %v", err)
+				continue
+			}
+			fmt.Fprintln(uiOut, diff)
+		}
+	}
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"io"
-	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
@@ -23,6 +26,23 @@ func getPrompt(tool string) string {
 	return claudePrompt
 }
 
+// promptFor returns the prompt for cfg.tool with the prd.json and
+// progress.txt references pointing at the PRD being worked on.
+func promptFor(cfg *config) string {
+	prompt := getPrompt(cfg.tool)
+	if cfg.ws.dir == "" || cfg.ws == defaultWorkspace(cfg.workDir) {
+		return prompt
+	}
+	rel := func(path string) string {
+		r, err := filepath.Rel(cfg.workDir, path)
+		if err != nil {
+			r = path
+		}
+		return filepath.ToSlash(r)
+	}
+	return strings.NewReplacer("prd.json", rel(cfg.ws.prd), "progress.txt", rel(filepath.Join(cfg.ws.dir, "progress.txt"))).Replace(prompt)
+}
+
 func getSkill(name string) string {
 	switch name {
 	case "prd":
@@ -34,42 +54,139 @@ func getSkill(name string) string {
 	}
 }
 
-func runTool(cfg *config) (string, error) {
+// runTool runs one iteration of the agent, piping prompt to its stdin. The
+// context bounds the run; when it is done the agent process is killed.
+func runTool(ctx context.Context, cfg *config, prompt string) (string, usage, error) {
 	var cmd *exec.Cmd
-	var stdinData string
 
 	if cfg.tool == "amp" {
-		stdinData = ampPrompt
-		cmd = exec.Command("amp", "--dangerously-allow-all")
+		cmd = exec.CommandContext(ctx, "amp", "--dangerously-allow-all")
 	} else {
-		stdinData = claudePrompt
-		cmd = exec.Command("claude", "--dangerously-skip-permissions", "--print")
+		args := []string{"--dangerously-skip-permissions", "--print", "--output-format", "stream-json", "--verbose"}
+		if cfg.model != "" {
+			args = append(args, "--model", cfg.model)
+		}
+		cmd = exec.CommandContext(ctx, "claude", args...)
 	}
 
 	cmd.Dir = cfg.workDir
 
 	var outputBuf bytes.Buffer
-	teeWriter := io.MultiWriter(os.Stderr, &outputBuf)
+	teeWriter := io.MultiWriter(agentOutput, &outputBuf)
 
-	cmd.Stdout = teeWriter
+	var stream *claudeStream
+	if cfg.tool == "amp" {
+		cmd.Stdout = teeWriter
+	} else {
+		// claude's events are echoed as text while it works
+		stream = &claudeStream{out: agentOutput}
+		cmd.Stdout = stream
+	}
 	cmd.Stderr = teeWriter
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		return "", err
+		return "", usage{}, err
 	}
 
 	if err := cmd.Start(); err != nil {
-		return "", err
+		return "", usage{}, err
 	}
 
-	stdin.Write([]byte(stdinData))
+	stdin.Write([]byte(prompt))
 	stdin.Close()
 
 	err = cmd.Wait()
-	output := outputBuf.String()
 
-	return output, err
+	var u usage
+	if stream != nil {
+		stream.flush()
+		outputBuf.WriteString(stream.raw.String())
+		if stream.done {
+			if !stream.streamed {
+				fmt.Fprintln(agentOutput, stream.result)
+			}
+			outputBuf.WriteString(stream.result)
+			u = stream.usage
+		}
+	}
+
+	return outputBuf.String(), u, err
+}
+
+// claudeStream reads `claude --output-format stream-json` as it arrives:
+// the assistant's text and tool calls are written to out right away, and
+// the final result event gives the reply and usage that runTool returns. A
+// lone result object, as `--output-format json` prints, is accepted too.
+// Lines that are not JSON, such as CLI errors, pass through and are kept
+// in raw.
+type claudeStream struct {
+	out      io.Writer
+	pending  []byte
+	raw      strings.Builder
+	result   string
+	usage    usage
+	done     bool // a result event was seen
+	streamed bool // assistant text was already written to out
+}
+
+type claudeEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+			Name string `json:"name"`
+		} `json:"content"`
+	} `json:"message"`
+}
+
+func (s *claudeStream) Write(p []byte) (int, error) {
+	s.pending = append(s.pending, p...)
+	for {
+		i := bytes.IndexByte(s.pending, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		s.line(string(s.pending[:i]))
+		s.pending = s.pending[i+1:]
+	}
+}
+
+// flush handles a last line that had no newline.
+func (s *claudeStream) flush() {
+	if len(s.pending) > 0 {
+		s.line(string(s.pending))
+		s.pending = nil
+	}
+}
+
+func (s *claudeStream) line(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	var ev claudeEvent
+	if err := json.Unmarshal([]byte(line), &ev); err != nil {
+		fmt.Fprintln(s.out, line)
+		s.raw.WriteString(line + "\n")
+		return
+	}
+	switch ev.Type {
+	case "assistant":
+		for _, c := range ev.Message.Content {
+			switch c.Type {
+			case "text":
+				fmt.Fprintln(s.out, c.Text)
+				s.streamed = true
+			case "tool_use":
+				fmt.Fprintf(s.out, "→ %s\n", c.Name)
+			}
+		}
+	case "result", "":
+		if text, u, ok := parseClaudeResult(line); ok {
+			s.result, s.usage, s.done = text, u, true
+		}
+	}
 }
 
 func containsCompletion(output string) bool {
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// tui is the full-screen dashboard for `ralph run --tui`. It redraws from
+// runState on a timer and turns key presses into state changes; the main
+// loop reacts to those between iterations.
+type tui struct {
+	state   *runState
+	width   int
+	height  int
+	restore func()
+	done    chan struct{}
+	drawn   chan struct{}
+	once    sync.Once
+
+	prevOut, prevErr, prevAgent io.Writer
+}
+
+func startTUI(state *runState) (*tui, error) {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return nil, fmt.Errorf("--tui needs an interactive terminal")
+	}
+	restore, err := makeRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tui{
+		state:     state,
+		restore:   restore,
+		done:      make(chan struct{}),
+		drawn:     make(chan struct{}),
+		prevOut:   uiOut,
+		prevErr:   uiErr,
+		prevAgent: agentOutput,
+	}
+	t.height, t.width = terminalSize()
+
+	// Everything that would normally scroll past goes into the panes.
+	uiOut, uiErr, agentOutput = state.log, state.log, state.output
+	outAnimate = false
+
+	fmt.Fprint(os.Stdout, "\033[?1049h\033[?25l")
+	go t.readKeys()
+	go t.drawLoop()
+	return t, nil
+}
+
+// stop restores the terminal. It is safe to call more than once.
+func (t *tui) stop() {
+	t.once.Do(func() {
+		close(t.done)
+		<-t.drawn
+		fmt.Fprint(os.Stdout, "\033[?25h\033[?1049l")
+		t.restore()
+		uiOut, uiErr, agentOutput = t.prevOut, t.prevErr, t.prevAgent
+	})
+}
+
+func (t *tui) readKeys() {
+	buf := make([]byte, 1)
+	for {
+		if _, err := os.Stdin.Read(buf); err != nil {
+			return
+		}
+		select {
+		case <-t.done:
+			return
+		default:
+		}
+		switch buf[0] {
+		case 'p', 'P', ' ':
+			if t.state.togglePause() {
+				logInfo("Will pause after the current iteration")
+			} else {
+				logInfo("Resume requested")
+			}
+		case 's', 'S':
+			if id := t.state.skip(""); id != "" {
+				logInfo("Skipping %s", id)
+			}
+		case 'q', 'Q':
+			logWarning("Aborting")
+			t.state.abort()
+		}
+	}
+}
+
+func (t *tui) drawLoop() {
+	defer close(t.drawn)
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		t.draw()
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *tui) draw() {
+	s := t.state
+	s.mu.Lock()
+	iteration, maxIter, phase := s.iteration, s.maxIterations, s.phase
+	spent, b, started, paused, waitUntil := s.spent, s.budget, s.startedAt, s.pause, s.waitUntil
+	skipped := append([]string(nil), s.skipped...)
+	s.mu.Unlock()
+
+	p, _, _ := loadPRDFile(s.ws.prd)
+	if p == nil {
+		p = &prd{Project: "unknown"}
+	}
+
+	var lines []string
+	add := func(format string, args ...any) { lines = append(lines, fmt.Sprintf(format, args...)) }
+
+	if !waitUntil.IsZero() {
+		phase += fmt.Sprintf(" (usage limit resets in %s)", time.Until(waitUntil).Round(time.Second))
+	}
+	if paused && phase != "paused" {
+		phase += " (pause requested)"
+	}
+	add(" %sRALPH%s  %s%s%s  %s   iteration %d/%d   %s%s%s",
+		colorOrcBlood+colorBold, colorReset, colorBold, p.Project, colorReset, p.BranchName,
+		iteration, maxIter, colorOrcGold, phase, colorReset)
+
+	elapsed := time.Since(started)
+	meters := []string{meter("time", elapsed.Round(time.Second).String(), elapsed.Seconds(), b.maxDuration.Seconds(), b.maxDuration.String())}
+	if b.maxCost > 0 || spent.costUSD > 0 {
+		meters = append(meters, meter("cost", fmt.Sprintf("$%.2f", spent.costUSD), spent.costUSD, b.maxCost, fmt.Sprintf("$%.2f", b.maxCost)))
+	}
+	if b.maxTokens > 0 || spent.tokens > 0 {
+		meters = append(meters, meter("tokens", fmt.Sprint(spent.tokens), float64(spent.tokens), float64(b.maxTokens), fmt.Sprint(b.maxTokens)))
+	}
+	add(" %s", strings.Join(meters, "   "))
+	add("")
+
+	storyRows := len(p.UserStories)
+	if limit := t.height / 4; storyRows > limit {
+		storyRows = limit
+	}
+	add(" %sSTORIES%s  %d/%d passing", colorBold, colorReset, p.passingCount(), len(p.UserStories))
+	next := nextStory(p, skipped)
+	for i, st := range p.UserStories {
+		if i >= storyRows {
+			add("   %s… %d more%s", colorMuted, len(p.UserStories)-storyRows, colorReset)
+			break
+		}
+		mark := colorOrcIron + "○" + colorReset
+		switch {
+		case st.Passes:
+			mark = colorSuccess + "✔" + colorReset
+		case contains(skipped, st.ID):
+			mark = colorMuted + "-" + colorReset
+		case next != nil && next.ID == st.ID:
+			mark = colorOrcGold + "▶" + colorReset
+		}
+		add("   [%s] %-8s %s", mark, st.ID, st.Title)
+	}
+	add("")
+
+	progress := recentProgress(s.ws.dir, 4)
+	logLines := s.log.tail(2)
+	// header + meters + gaps + titles + progress + log + footer
+	fixed := len(lines) + 1 + 1 + len(progress) + 1 + len(logLines) + 1 + 1
+	outRows := t.height - fixed
+	if outRows < 3 {
+		outRows = 3
+	}
+
+	add(" %sOUTPUT%s", colorBold, colorReset)
+	out := s.output.tail(outRows)
+	for len(out) < outRows {
+		out = append(out, "")
+	}
+	for _, l := range out {
+		add("   %s%s%s", colorMuted, stripANSI(l), colorReset)
+	}
+
+	add(" %sPROGRESS%s", colorBold, colorReset)
+	for _, l := range progress {
+		add("   %s", l)
+	}
+	add("")
+	for _, l := range logLines {
+		add(" %s", strings.TrimSpace(stripANSI(l)))
+	}
+
+	pauseKey := "pause"
+	if paused {
+		pauseKey = "resume"
+	}
+	add(" %sp%s %s   %ss%s skip story   %sq%s abort", colorOrcGold, colorReset, pauseKey, colorOrcGold, colorReset, colorOrcGold, colorReset)
+
+	if len(lines) > t.height {
+		lines = lines[:t.height]
+	}
+	var sb strings.Builder
+	sb.WriteString("\033[H")
+	for i, l := range lines {
+		sb.WriteString(truncateANSI(l, t.width))
+		sb.WriteString("\033[K")
+		if i < len(lines)-1 {
+			sb.WriteString("\r\n")
+		}
+	}
+	sb.WriteString("\033[J")
+	fmt.Fprint(os.Stdout, sb.String())
+}
+
+// meter renders "name value [━━━───] limit", or just "name value" when the
+// budget is unlimited.
+func meter(name, value string, current, limit float64, limitLabel string) string {
+	m := fmt.Sprintf("%s%s%s %s", colorMuted, name, colorReset, value)
+	if limit <= 0 {
+		return m
+	}
+	const width = 12
+	filled := int(current / limit * width)
+	if filled > width {
+		filled = width
+	}
+	return fmt.Sprintf("%s %s%s%s%s%s / %s", m,
+		colorAccent, strings.Repeat("━", filled), colorMuted, strings.Repeat("─", width-filled), colorReset, limitLabel)
+}
+
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+func stripANSI(s string) string {
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+// truncateANSI cuts s to width visible runes, keeping escape sequences
+// intact and resetting colors if anything was cut.
+func truncateANSI(s string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(stripANSI(s)) <= width {
+		return s
+	}
+	var sb strings.Builder
+	visible := 0
+	for i := 0; i < len(s) && visible < width; {
+		if s[i] == 0x1b {
+			if loc := ansiPattern.FindStringIndex(s[i:]); loc != nil && loc[0] == 0 {
+				sb.WriteString(s[i : i+loc[1]])
+				i += loc[1]
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == '\t' {
+			r = ' '
+		}
+		sb.WriteRune(r)
+		i += size
+		visible++
+	}
+	sb.WriteString(colorReset)
+	return sb.String()
+}
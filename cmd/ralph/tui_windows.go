@@ -0,0 +1,13 @@
+//go:build windows
+
+package main
+
+import "fmt"
+
+func makeRaw() (func(), error) {
+	return nil, fmt.Errorf("--tui is not supported on Windows")
+}
+
+func terminalSize() (int, int) {
+	return 24, 80
+}
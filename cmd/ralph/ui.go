@@ -2,13 +2,12 @@ package main
 
 import (
 	"fmt"
-	"os"
 	"strings"
 	"sync"
 	"time"
 )
 
-const (
+var (
 	colorReset = "\033[0m"
 	colorBold  = "\033[1m"
 	colorDim   = "\033[2m"
@@ -29,6 +28,14 @@ const (
 	colorAccent  = colorOrcBlood
 )
 
+// disableColors blanks every color code so the same format strings print
+// plain text.
+func disableColors() {
+	colorReset, colorBold, colorDim = "", "", ""
+	colorOrcBlood, colorOrcIron, colorOrcGold, colorOrcRust, colorOrcSparks = "", "", "", "", ""
+	colorSuccess, colorWarning, colorError, colorInfo, colorMuted, colorAccent = "", "", "", "", "", ""
+}
+
 // Forge animation (Hammer & Anvil)
 var forgeFrames = []string{
 	"  🔨      ",
@@ -50,6 +57,7 @@ type spinner struct {
 	message string
 	stop    chan struct{}
 	done    chan struct{}
+	started bool
 	mu      sync.Mutex
 }
 
@@ -63,6 +71,10 @@ func newSpinner(message string) *spinner {
 }
 
 func (s *spinner) Start() {
+	if !outAnimate {
+		return
+	}
+	s.started = true
 	go func() {
 		ticker := time.NewTicker(80 * time.Millisecond)
 		defer ticker.Stop()
@@ -71,7 +83,7 @@ func (s *spinner) Start() {
 		for {
 			select {
 			case <-s.stop:
-				fmt.Printf("\r\033[K")
+				fmt.Fprintf(uiOut, "\r\033[K")
 				return
 			case <-ticker.C:
 				s.mu.Lock()
@@ -79,13 +91,16 @@ func (s *spinner) Start() {
 				msg := s.message
 				s.current = (s.current + 1) % len(s.frames)
 				s.mu.Unlock()
-				fmt.Printf("\r%s%s%s %s", colorOrcIron, frame, colorReset, msg)
+				fmt.Fprintf(uiOut, "\r%s%s%s %s", colorOrcIron, frame, colorReset, msg)
 			}
 		}
 	}()
 }
 
 func (s *spinner) Stop() {
+	if !s.started {
+		return
+	}
 	close(s.stop)
 	<-s.done
 }
@@ -106,6 +121,14 @@ type statusLine struct {
 }
 
 func printStatusLine(line statusLine) {
+	if outMode == outputJSON {
+		emit("status", map[string]any{"id": line.id, "done": line.done, "elapsed_seconds": line.elapsed.Seconds()})
+		return
+	}
+	if outQuiet {
+		return
+	}
+
 	var marker string
 	if line.done {
 		marker = fmt.Sprintf("%s%s%s", colorSuccess, "ready", colorReset)
@@ -118,7 +141,7 @@ func printStatusLine(line statusLine) {
 		elapsed = fmt.Sprintf(" %s%s%s", colorMuted, line.elapsed.Round(time.Second), colorReset)
 	}
 
-	fmt.Printf("  %s[%-6s]%s %s %s%s%s\n", colorOrcRust, line.id, colorReset, marker, colorDim, elapsed, colorReset)
+	fmt.Fprintf(uiOut, "  %s[%-6s]%s %s %s%s%s\n", colorOrcRust, line.id, colorReset, marker, colorDim, elapsed, colorReset)
 }
 
 func progressBar(current, total int, width int) string {
@@ -136,26 +159,73 @@ func progressBar(current, total int, width int) string {
 	return fmt.Sprintf("%s %3d%%", bar, percent)
 }
 
+func printIterationHeader(i, maxIter int) {
+	if outMode == outputJSON {
+		emit("iteration_start", map[string]any{"iteration": i, "max_iterations": maxIter})
+		return
+	}
+	if outQuiet {
+		return
+	}
+	fmt.Fprintf(uiOut, "\n  %s%d/%d%s    %s\n", colorAccent, i, maxIter, colorReset, progressBar(i-1, maxIter, 24))
+}
+
+// printFinish reports how the run ended. label is the short outcome word
+// shown in the left column of text output; details are extra muted lines.
+func printFinish(code int, label, color, message string, details ...string) {
+	if outMode == outputJSON {
+		emit("finish", map[string]any{"outcome": exitCodeNames[code], "exit_code": code, "message": message, "details": details})
+		return
+	}
+	fmt.Fprintln(uiOut)
+	fmt.Fprintf(uiOut, "  %s%-9s%s %s\n", color, label, colorReset, message)
+	for _, d := range details {
+		fmt.Fprintf(uiOut, "  %s          %s%s\n", colorMuted, d, colorReset)
+	}
+	fmt.Fprintln(uiOut)
+}
+
 func printBanner(tool string, maxIter int, p *prd, ver string) {
-	fmt.Printf("\n%s", colorOrcIron)
-	fmt.Println(`             /\          /\          /\`)
-	fmt.Printf("      ______/  \\________/  \\________/  \\______\n")
-	fmt.Printf("     |  %s██████╗  █████╗ ██╗   ██████╗ ██╗  ██╗%s  |\n", colorOrcBlood, colorOrcIron)
-	fmt.Printf("     |  %s██╔══██╗██╔══██╗██║   ██╔══██╗██║  ██║%s  |\n", colorOrcBlood, colorOrcIron)
-	fmt.Printf("     |  %s██████╔╝███████║██║   ██████╔╝███████║%s  |\n", colorOrcBlood, colorOrcIron)
-	fmt.Printf("     |  %s██╔══██╗██╔══██║██║   ██╔═══╝ ██╔══██║%s  |\n", colorOrcBlood, colorOrcIron)
-	fmt.Printf("     |  %s██║  ██║██║  ██║█████╗██║     ██║  ██║%s  |\n", colorOrcBlood, colorOrcIron)
-	fmt.Printf("     |  %s╚═╝  ╚═╝╚═╝  ╚═╝╚════╝╚═╝     ╚═╝  ╚═╝%s  |\n", colorOrcBlood, colorOrcIron)
-	fmt.Printf("      \\______    ________    ________    ______/\n")
-	fmt.Println(`             \/          \/          \/`)
-	fmt.Printf("%s\n", colorReset)
-	fmt.Printf("  %s[TOOL  ]%s %s%s%s (v%s)\n", colorMuted, colorReset, colorBold, tool, colorReset, ver)
-	fmt.Printf("  %s[GOAL  ]%s  %s\n", colorMuted, colorReset, p.Project)
-	fmt.Printf("  %s[BRANCH]%s     %s%s%s\n", colorMuted, colorReset, colorOrcGold, p.BranchName, colorReset)
-	fmt.Printf("  %s[LIMIT ]%s    %d\n\n", colorMuted, colorReset, maxIter)
+	if outMode == outputJSON {
+		stories := make([]map[string]any, 0, len(p.UserStories))
+		for _, s := range p.UserStories {
+			stories = append(stories, map[string]any{"id": s.ID, "title": s.Title, "passes": s.Passes})
+		}
+		emit("start", map[string]any{"tool": tool, "version": ver, "project": p.Project, "branch": p.BranchName, "max_iterations": maxIter, "stories": stories})
+		return
+	}
+	if outQuiet {
+		return
+	}
+	if outMode == outputText {
+		printBannerArt()
+	}
+	printBannerInfo(tool, maxIter, p, ver)
+}
+
+func printBannerArt() {
+	fmt.Fprintf(uiOut, "\n%s", colorOrcIron)
+	fmt.Fprintln(uiOut, `             /\          /\          /\`)
+	fmt.Fprintf(uiOut, "      ______/  \\________/  \\________/  \\______\n")
+	fmt.Fprintf(uiOut, "     |  %s██████╗  █████╗ ██╗   ██████╗ ██╗  ██╗%s  |\n", colorOrcBlood, colorOrcIron)
+	fmt.Fprintf(uiOut, "     |  %s██╔══██╗██╔══██╗██║   ██╔══██╗██║  ██║%s  |\n", colorOrcBlood, colorOrcIron)
+	fmt.Fprintf(uiOut, "     |  %s██████╔╝███████║██║   ██████╔╝███████║%s  |\n", colorOrcBlood, colorOrcIron)
+	fmt.Fprintf(uiOut, "     |  %s██╔══██╗██╔══██║██║   ██╔═══╝ ██╔══██║%s  |\n", colorOrcBlood, colorOrcIron)
+	fmt.Fprintf(uiOut, "     |  %s██║  ██║██║  ██║█████╗██║     ██║  ██║%s  |\n", colorOrcBlood, colorOrcIron)
+	fmt.Fprintf(uiOut, "     |  %s╚═╝  ╚═╝╚═╝  ╚═╝╚════╝╚═╝     ╚═╝  ╚═╝%s  |\n", colorOrcBlood, colorOrcIron)
+	fmt.Fprintf(uiOut, "      \\______    ________    ________    ______/\n")
+	fmt.Fprintln(uiOut, `             \/          \/          \/`)
+	fmt.Fprintf(uiOut, "%s\n", colorReset)
+}
+
+func printBannerInfo(tool string, maxIter int, p *prd, ver string) {
+	fmt.Fprintf(uiOut, "  %s[TOOL  ]%s %s%s%s (v%s)\n", colorMuted, colorReset, colorBold, tool, colorReset, ver)
+	fmt.Fprintf(uiOut, "  %s[GOAL  ]%s  %s\n", colorMuted, colorReset, p.Project)
+	fmt.Fprintf(uiOut, "  %s[BRANCH]%s     %s%s%s\n", colorMuted, colorReset, colorOrcGold, p.BranchName, colorReset)
+	fmt.Fprintf(uiOut, "  %s[LIMIT ]%s    %d\n\n", colorMuted, colorReset, maxIter)
 
 	if len(p.UserStories) > 0 {
-		fmt.Printf("  %sTASKS:%s\n", colorBold, colorReset)
+		fmt.Fprintf(uiOut, "  %sTASKS:%s\n", colorBold, colorReset)
 		for _, s := range p.UserStories {
 			status := " "
 			if s.Passes {
@@ -163,29 +233,55 @@ func printBanner(tool string, maxIter int, p *prd, ver string) {
 			} else {
 				status = fmt.Sprintf("%s○%s", colorOrcIron, colorReset)
 			}
-			fmt.Printf("    [%s] %-8s %s\n", status, s.ID, s.Title)
+			fmt.Fprintf(uiOut, "    [%s] %-8s %s\n", status, s.ID, s.Title)
 		}
-		fmt.Println()
+		fmt.Fprintln(uiOut)
 	}
 }
 
 func logInfo(format string, args ...any) {
-	fmt.Printf("  %s[ZUG ZUG]%s  %s\n", colorInfo, colorReset, fmt.Sprintf(format, args...))
+	if outQuiet {
+		return
+	}
+	if outMode == outputJSON {
+		emitLog("info", format, args...)
+		return
+	}
+	fmt.Fprintf(uiOut, "  %s[ZUG ZUG]%s  %s\n", colorInfo, colorReset, fmt.Sprintf(format, args...))
 }
 
 func logSuccess(format string, args ...any) {
-	fmt.Printf("  %s[DABU]%s     %s\n", colorSuccess, colorReset, fmt.Sprintf(format, args...))
+	if outQuiet {
+		return
+	}
+	if outMode == outputJSON {
+		emitLog("success", format, args...)
+		return
+	}
+	fmt.Fprintf(uiOut, "  %s[DABU]%s     %s\n", colorSuccess, colorReset, fmt.Sprintf(format, args...))
 }
 
 func logWarning(format string, args ...any) {
-	fmt.Printf("  %s[SWAB]%s     %s\n", colorWarning, colorReset, fmt.Sprintf(format, args...))
+	if outMode == outputJSON {
+		emitLog("warning", format, args...)
+		return
+	}
+	fmt.Fprintf(uiOut, "  %s[SWAB]%s     %s\n", colorWarning, colorReset, fmt.Sprintf(format, args...))
 }
 
 func logError(format string, args ...any) {
-	fmt.Fprintf(os.Stderr, "  %s[LOK-TAR!]%s %s\n", colorError, colorReset, fmt.Sprintf(format, args...))
+	if outMode == outputJSON {
+		emitLog("error", format, args...)
+		return
+	}
+	fmt.Fprintf(uiErr, "  %s[LOK-TAR!]%s %s\n", colorError, colorReset, fmt.Sprintf(format, args...))
+}
+
+func emitLog(level, format string, args ...any) {
+	emit("log", map[string]any{"level": level, "message": fmt.Sprintf(format, args...)})
 }
 
 func logStep(step, total int, format string, args ...any) {
 	msg := fmt.Sprintf(format, args...)
-	fmt.Printf("  %s[%d/%d]%s  %s\n", colorAccent, step, total, colorReset, msg)
+	fmt.Fprintf(uiOut, "  %s[%d/%d]%s  %s\n", colorAccent, step, total, colorReset, msg)
 }